@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/gorilla/mux"
+)
+
+// NewBookingRouter creates a subrouter for booking endpoints
+func NewBookingRouter(router *mux.Router) {
+	bookingRouter := router.PathPrefix("/booking").Subrouter()
+	bookingRouter.HandleFunc("", BookingHandler).Methods("POST")
+	bookingRouter.HandleFunc("", BookingListHandler).Methods("GET")
+	bookingRouter.HandleFunc("/{id}/cancel", BookingCancelHandler).Methods("PUT")
+}
+
+// BookingHandler books a space for a future time window
+func BookingHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.BookingRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+
+		return
+	}
+
+	id, err := usecases.MakeBooking(callerOf(r), request)
+	if err != nil {
+		if invalid, ok := err.(models.Invalid); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(validationToJSON(invalid, callerOf(r).Lot))
+
+			return
+		}
+		switch err {
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(stringToJSON(err.Error()))
+		case utils.ErrLotFull:
+			w.WriteHeader(http.StatusConflict)
+			w.Write(stringToJSON(err.Error()))
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(idToJSON(id))
+}
+
+// BookingListHandler lists bookings, filtered by the plate and status query parameters
+func BookingListHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	bookings, err := usecases.GetBookings(callerOf(r), query.Get("plate"), query.Get("status"))
+	if err != nil {
+		switch err {
+		case utils.ErrPlateNotValid:
+			w.WriteHeader(http.StatusBadRequest)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write(errorToJSON(err, callerOf(r).Lot))
+
+		return
+	}
+
+	json, err := json.Marshal(bookings)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(utils.ErrInternalServer.Error()))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(json)
+}
+
+// BookingCancelHandler cancels a booking
+func BookingCancelHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := usecases.CancelBooking(callerOf(r), vars["id"]); err != nil {
+		switch err {
+		case utils.ErrIDNotValid:
+			w.WriteHeader(http.StatusBadRequest)
+		case utils.ErrBookingClosed:
+			w.WriteHeader(http.StatusConflict)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		case utils.ErrNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(stringToJSON("Cancelled"))
+}
@@ -0,0 +1,112 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"br.com.mlabs/api"
+	"br.com.mlabs/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookingHappyPath(t *testing.T) {
+	request := models.BookingRequest{
+		Plate:    "ABC-1234",
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(3 * time.Hour),
+	}
+	jsonBytes, _ := json.Marshal(request)
+
+	req, _ := http.NewRequest(http.MethodPost, "/booking", bytes.NewBuffer(jsonBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewBookingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.Contains(string(bts), "{\"id\":"), true)
+}
+
+func TestBookingValidationError(t *testing.T) {
+	request := models.BookingRequest{
+		Plate:    "ABC-1234",
+		StartsAt: time.Now().Add(-time.Hour),
+		EndsAt:   time.Now().Add(time.Hour),
+	}
+	jsonBytes, _ := json.Marshal(request)
+
+	req, _ := http.NewRequest(http.MethodPost, "/booking", bytes.NewBuffer(jsonBytes))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewBookingRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.HasPrefix(string(bts), "{\"response\":\"Booking must have a valid plate and a future time window\",\"errors\":[{\"field\":\"starts_at\",\"rule\":\"gtfield\""), true)
+	assert.Equal(t, strings.HasSuffix(string(bts), "\"message\":\"must be after now\"}]}"), true)
+}
+
+func TestBookingList(t *testing.T) {
+	booking := models.Booking{
+		LotID:    caller.Lot.ID,
+		Plate:    "LST-1234",
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(2 * time.Hour),
+		Status:   models.BookingBooked,
+	}
+	tx.Create(&booking)
+
+	req, _ := http.NewRequest(http.MethodGet, "/booking?plate=LST-1234", nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewBookingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.Contains(string(bts), fmt.Sprintf("{\"id\":%d,", booking.ID)), true)
+}
+
+func TestBookingCancelHappyPath(t *testing.T) {
+	booking := models.Booking{
+		LotID:    caller.Lot.ID,
+		Plate:    "CNL-1234",
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(2 * time.Hour),
+		Status:   models.BookingBooked,
+	}
+	tx.Create(&booking)
+
+	url := fmt.Sprintf("/booking/%d/cancel", booking.ID)
+
+	req, _ := http.NewRequest(http.MethodPut, url, nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewBookingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Cancelled\"}")
+
+	response = executeRequest(req, api.NewBookingRouter)
+
+	assert.Equal(t, response.Code, http.StatusConflict)
+	bts, _ = ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Booking is no longer active\"}")
+}
+
+func TestBookingCancelNotFound(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "/booking/9999/cancel", nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewBookingRouter)
+
+	assert.Equal(t, response.Code, http.StatusNotFound)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Not found\"}")
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"br.com.mlabs/export"
+	"br.com.mlabs/reports"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// NewExportRouter creates a subrouter for export endpoints
+func NewExportRouter(router *mux.Router) {
+	exportRouter := router.PathPrefix("/export").Subrouter()
+	exportRouter.HandleFunc("/tickets", ExportHandler).Methods("GET")
+}
+
+// ExportHandler streams the tickets and payments of a date range as CSV or NDJSON
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	rng, err := reports.ParseRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	options, err := export.ParseOptions(rng, query.Get("format"), query.Get("columns"), query.Get("gzip") == "true")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	switch {
+	case options.Gzip:
+		w.Header().Set("Content-Type", "application/gzip")
+	case options.Format == export.CSV:
+		w.Header().Set("Content-Type", "text/csv")
+	default:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", options.Filename()))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are gone by now, a failure can only cut the stream short
+	if err := export.Export(w, callerOf(r).Lot.ID, options); err != nil {
+		logrus.Warnf("Export interrupted: %s", err.Error())
+	}
+}
@@ -0,0 +1,31 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"br.com.mlabs/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportCSV(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/export/tickets?from=2019-02-01&to=2019-02-02&columns=id,plate", nil)
+
+	response := executeRequest(req, api.NewExportRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, response.Header().Get("Content-Disposition"), "attachment; filename=tickets-2019-02-01-2019-02-02.csv")
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "id,plate\n")
+}
+
+func TestExportNotValid(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/export/tickets?from=2020-11-01&to=2020-11-30&format=xml", nil)
+
+	response := executeRequest(req, api.NewExportRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Export must be csv or ndjson, columns: id, plate, checkin, checkout, prepaid, paid, amount, method, paid_at\"}")
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/gorilla/mux"
+)
+
+// NewLotRouter creates a subrouter for the lots and users admins manage
+func NewLotRouter(router *mux.Router) {
+	lotRouter := router.PathPrefix("/lots").Subrouter()
+	lotRouter.HandleFunc("", LotListHandler).Methods("GET")
+	lotRouter.HandleFunc("", LotHandler).Methods("POST")
+	lotRouter.HandleFunc("/{id}/users", UserHandler).Methods("POST")
+}
+
+// LotListHandler lists the lots the admin sees
+func LotListHandler(w http.ResponseWriter, r *http.Request) {
+	lots, err := usecases.GetLots(callerOf(r))
+	if err != nil {
+		switch err {
+		case utils.ErrForbidden:
+			w.WriteHeader(http.StatusForbidden)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	writeJSON(w, lots)
+}
+
+// LotHandler creates a lot in the admin's company, with its own spaces and tariffs by category
+func LotHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.LotRequest
+
+	if fields := decodeStrict(r.Body, &request); fields != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(badRequestToJSON(fields))
+
+		return
+	}
+
+	id, err := usecases.MakeLot(callerOf(r), request)
+	if err != nil {
+		if invalid, ok := err.(models.Invalid); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(validationToJSON(invalid, callerOf(r).Lot))
+
+			return
+		}
+		switch err {
+		case utils.ErrForbidden:
+			w.WriteHeader(http.StatusForbidden)
+		case utils.ErrLotExists:
+			w.WriteHeader(http.StatusConflict)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(idToJSON(id))
+}
+
+// UserHandler creates a user of a lot, the response has its API key, which is not shown again
+func UserHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var request models.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+
+		return
+	}
+
+	key, err := usecases.MakeUser(callerOf(r), vars["id"], request)
+	if err != nil {
+		if invalid, ok := err.(models.Invalid); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(validationToJSON(invalid, callerOf(r).Lot))
+
+			return
+		}
+		switch err {
+		case utils.ErrIDNotValid:
+			w.WriteHeader(http.StatusBadRequest)
+		case utils.ErrForbidden:
+			w.WriteHeader(http.StatusForbidden)
+		case utils.ErrNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	writeJSON(w, key)
+}
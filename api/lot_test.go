@@ -0,0 +1,191 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"br.com.mlabs/api"
+	"br.com.mlabs/models"
+	"br.com.mlabs/usecases"
+	"github.com/stretchr/testify/assert"
+)
+
+// adminKey creates a lot in a company with an admin and returns the admin's API key
+func adminKey(t *testing.T, company string, lot string) string {
+	if _, err := usecases.CreateLot(company, models.LotRequest{Name: lot}); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := usecases.CreateUser(lot, models.UserRequest{Name: "Admin", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key.Key
+}
+
+func TestAPIKeys(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/parking/ABC-1234", nil)
+	req.Header.Set("Authorization", "Bearer notakey")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusUnauthorized)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"API key must be valid\"}")
+
+	os.Setenv("API_KEYS_REQUIRED", "true")
+	defer os.Unsetenv("API_KEYS_REQUIRED")
+
+	req, _ = http.NewRequest(http.MethodGet, "/parking/ABC-1234", nil)
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusUnauthorized)
+}
+
+func TestLots(t *testing.T) {
+	// Requests without a key are made by an operator of the default lot
+	req, _ := http.NewRequest(http.MethodGet, "/lots", nil)
+	response := executeRequest(req, api.NewLotRouter)
+	assert.Equal(t, response.Code, http.StatusForbidden)
+
+	key := adminKey(t, "API Company", "API Head")
+
+	req, _ = http.NewRequest(http.MethodPost, "/lots", bytes.NewBufferString(`{"name":"API Branch","spaces":10}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req, api.NewLotRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Bad request\",\"errors\":[{\"field\":\"spaces\",\"rule\":\"unknown\",\"message\":\"is not a known field\"}]}")
+
+	req, _ = http.NewRequest(http.MethodPost, "/lots", bytes.NewBufferString(`{"name":"API Branch","categories":[{"category":"car","spaces":10,"first_hour":900}]}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req, api.NewLotRouter)
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	var created struct {
+		ID uint `json:"id"`
+	}
+	json.NewDecoder(response.Body).Decode(&created)
+
+	req, _ = http.NewRequest(http.MethodPost, "/lots", bytes.NewBufferString(`{"name":"API Branch"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req, api.NewLotRouter)
+
+	assert.Equal(t, response.Code, http.StatusConflict)
+	bts, _ = ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"There is already a lot with this name\"}")
+
+	req, _ = http.NewRequest(http.MethodGet, "/lots", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req, api.NewLotRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	var lots []models.LotEntry
+	json.NewDecoder(response.Body).Decode(&lots)
+	assert.Equal(t, len(lots), 2)
+	assert.Equal(t, lots[0].Name, "API Branch")
+	assert.Equal(t, lots[0].Company, "API Company")
+
+	// A user of the new lot only sees its tickets
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/lots/%d/users", created.ID), bytes.NewBufferString(`{"name":"Gate","role":"operator"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req, api.NewLotRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	var user models.UserKey
+	json.NewDecoder(response.Body).Decode(&user)
+
+	jsonBytes, _ := json.Marshal(models.ParkingRequest{Plate: "LOT-4321"})
+	req, _ = http.NewRequest(http.MethodPost, "/parking", bytes.NewBuffer(jsonBytes))
+	req.Header.Set("Authorization", "Bearer "+user.Key)
+	response = executeRequest(req, api.NewParkingRouter)
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	req, _ = http.NewRequest(http.MethodGet, "/parking/LOT-4321", nil)
+	req.Header.Set("Authorization", "Bearer "+user.Key)
+	response = executeRequest(req, api.NewParkingRouter)
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	req, _ = http.NewRequest(http.MethodGet, "/parking/LOT-4321", nil)
+	response = executeRequest(req, api.NewParkingRouter)
+	assert.Equal(t, response.Code, http.StatusNotFound)
+
+	// The admin of the company sees it in every lot
+	req, _ = http.NewRequest(http.MethodGet, "/parking/LOT-4321?lots=all", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	var history models.ParkingHistory
+	json.NewDecoder(response.Body).Decode(&history)
+	assert.Equal(t, len(history), 1)
+	assert.Equal(t, history[0].Lot, "API Branch")
+
+	req, _ = http.NewRequest(http.MethodGet, "/parking/LOT-4321?lots=all", nil)
+	req.Header.Set("Authorization", "Bearer "+user.Key)
+	response = executeRequest(req, api.NewParkingRouter)
+	assert.Equal(t, response.Code, http.StatusForbidden)
+}
+
+func TestUserNotValid(t *testing.T) {
+	key := adminKey(t, "API Users", "API Users Lot")
+
+	req, _ := http.NewRequest(http.MethodPost, "/lots/notvalid/users", bytes.NewBufferString(`{"name":"Gate","role":"operator"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response := executeRequest(req, api.NewLotRouter)
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+
+	req, _ = http.NewRequest(http.MethodPost, "/lots/999999/users", bytes.NewBufferString(`{"name":"Gate","role":"operator"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req, api.NewLotRouter)
+	assert.Equal(t, response.Code, http.StatusNotFound)
+
+	admin, _ := usecases.Authenticate(key)
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/lots/%d/users", admin.Lot.ID), bytes.NewBufferString(`{"name":"Gate","role":"owner"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response = executeRequest(req, api.NewLotRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"User must have a name and a role: operator, admin\",\"errors\":[{\"field\":\"role\",\"rule\":\"oneof\",\"value\":\"owner\",\"message\":\"must be one of: operator, admin\"}]}")
+}
+
+func TestUserOtherCompany(t *testing.T) {
+	key := adminKey(t, "", "API Alone")
+	other, _ := usecases.Authenticate(adminKey(t, "API Other", "API Other Lot"))
+
+	// An admin of a lot without a company does not see the lots of companies
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/lots/%d/users", other.Lot.ID), bytes.NewBufferString(`{"name":"Gate","role":"admin"}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response := executeRequest(req, api.NewLotRouter)
+
+	assert.Equal(t, response.Code, http.StatusNotFound)
+}
+
+func TestLotPlateFormats(t *testing.T) {
+	key := adminKey(t, "API Formats", "API Formats Head")
+
+	req, _ := http.NewRequest(http.MethodPost, "/lots", bytes.NewBufferString(`{"name":"API Montevideo","plate_formats":["uy-mercosul"]}`))
+	req.Header.Set("Authorization", "Bearer "+key)
+	response := executeRequest(req, api.NewLotRouter)
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	user, err := usecases.CreateUser("API Montevideo", models.UserRequest{Name: "Gate", Role: models.RoleOperator})
+	assert.Equal(t, err, nil)
+
+	// Invalid plates are answered with the formats of the caller's lot
+	req, _ = http.NewRequest(http.MethodGet, "/parking/ABC-123", nil)
+	req.Header.Set("Authorization", "Bearer "+user.Key)
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Plate must be valid, formats: ABC 1234\"}")
+}
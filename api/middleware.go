@@ -1,8 +1,19 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strings"
 
+	"br.com.mlabs/config"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/usecases"
 	"br.com.mlabs/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -24,3 +35,176 @@ func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 	w.Write([]byte(utils.ErrNotFound.Error()))
 }
+
+// callerKey keeps the caller of a request in its context
+type callerKey struct{}
+
+// Authenticate finds the caller of a request by the API key in the "Authorization: Bearer" header.
+// Requests without a key work in the default lot, unless API_KEYS_REQUIRED is set
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var caller models.Caller
+		var err error
+
+		header := r.Header.Get("Authorization")
+		switch {
+		case header != "":
+			caller, err = usecases.Authenticate(strings.TrimPrefix(header, "Bearer "))
+		case config.APIKeysRequired():
+			err = utils.ErrUnauthorized
+		default:
+			caller, err = usecases.DefaultCaller()
+		}
+		if err != nil {
+			switch err {
+			case utils.ErrUnauthorized:
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				w.WriteHeader(http.StatusUnauthorized)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			w.Write(stringToJSON(err.Error()))
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, caller)))
+	})
+}
+
+// callerOf is the caller Authenticate found for the request
+func callerOf(r *http.Request) models.Caller {
+	caller, _ := r.Context().Value(callerKey{}).(models.Caller)
+	return caller
+}
+
+// LimitBody refuses request bodies over max bytes with the tooLarge error, declared ones right away and the rest as they are read
+func LimitBody(max int64, tooLarge error, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write(stringToJSON(tooLarge.Error()))
+
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next(w, r)
+	}
+}
+
+// bodyTooLarge tells if reading failed on the LimitBody limit, the error is only typed in newer Go versions
+func bodyTooLarge(err error) bool {
+	return strings.HasSuffix(err.Error(), "http: request body too large")
+}
+
+// Idempotent replays the stored response when a request is retried with the same Idempotency-Key header.
+// Keys are kept per lot, two lots may use the same key. The body is buffered up to UPLOAD_MAX_BYTES,
+// routes taking smaller bodies limit them first with LimitBody
+func Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		key = fmt.Sprintf("%d:%s", callerOf(r).Lot.ID, key)
+
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, config.UploadMaxBytes()))
+			if err != nil {
+				if bodyTooLarge(err) {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					w.Write(stringToJSON(utils.ErrBodyTooLarge.Error()))
+
+					return
+				}
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.Path)
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		stored, claimed, err := storage.ClaimIdempotencyKey(key, fingerprint, config.IdempotencyLease())
+		if err != nil {
+			switch err {
+			case utils.ErrIdempotencyInProgress:
+				w.WriteHeader(http.StatusConflict)
+			case utils.ErrInternalServer:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			w.Write(stringToJSON(err.Error()))
+
+			return
+		}
+
+		if !claimed {
+			switch {
+			case stored.Fingerprint != fingerprint:
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write(stringToJSON(utils.ErrIdempotencyKeyReused.Error()))
+			case stored.Status == 0:
+				w.WriteHeader(http.StatusConflict)
+				w.Write(stringToJSON(utils.ErrIdempotencyInProgress.Error()))
+			default:
+				if stored.ContentType != "" {
+					w.Header().Set("Content-Type", stored.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.Status)
+				w.Write(stored.Body)
+			}
+
+			return
+		}
+
+		// A panicking handler leaves no response to replay, the client may retry it
+		defer func() {
+			if p := recover(); p != nil {
+				storage.ReleaseIdempotencyKey(key)
+				panic(p)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		// Server errors are not kept, the client may retry them
+		if recorder.status >= http.StatusInternalServerError {
+			storage.ReleaseIdempotencyKey(key)
+			return
+		}
+
+		err = storage.SaveIdempotentResponse(key, recorder.status, w.Header().Get("Content-Type"), recorder.body.Bytes(), config.IdempotencyTTL())
+		if err != nil {
+			logrus.Warnf("Response to idempotency key %s was not kept, a retry runs again", key)
+			storage.ReleaseIdempotencyKey(key)
+		}
+	}
+}
+
+// responseRecorder writes through while keeping a copy of the response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(bts []byte) (int, error) {
+	rec.body.Write(bts)
+	return rec.ResponseWriter.Write(bts)
+}
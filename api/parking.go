@@ -3,10 +3,18 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"strings"
 
+	"br.com.mlabs/config"
+	"br.com.mlabs/images"
 	"br.com.mlabs/models"
+	"br.com.mlabs/plates"
+	"br.com.mlabs/receipt"
+	"br.com.mlabs/ticket"
 	"br.com.mlabs/usecases"
 	"br.com.mlabs/utils"
 	"github.com/gorilla/mux"
@@ -17,16 +25,55 @@ import (
 func NewParkingRouter(router *mux.Router) {
 	parkingRouter := router.PathPrefix("/parking").Subrouter()
 	parkingRouter.HandleFunc("/{plate}", HistoryHandler).Methods("GET")
-	parkingRouter.HandleFunc("/in", ImageRecognitionHandler).Methods("POST")
+	parkingRouter.HandleFunc("/in", LimitBody(config.UploadMaxBytes(), utils.ErrImageTooLarge, Idempotent(ImageRecognitionHandler))).Methods("POST")
+	parkingRouter.HandleFunc("/out", LimitBody(config.UploadMaxBytes(), utils.ErrImageTooLarge, ExitHandler)).Methods("POST")
+	parkingRouter.HandleFunc("/import", ImportHandler).Methods("POST")
 	parkingRouter.HandleFunc("/{id}/out", CheckoutHandler).Methods("PUT")
-	parkingRouter.HandleFunc("/{id}/pay", PayHandler).Methods("PUT")
-	parkingRouter.HandleFunc("", ReservationHandler).Methods("POST")
+	parkingRouter.HandleFunc("/{id}/pay", LimitBody(config.JSONMaxBytes(), utils.ErrBodyTooLarge, Idempotent(PayHandler))).Methods("PUT")
+	parkingRouter.HandleFunc("/{id}/ticket", TicketHandler).Methods("GET")
+	parkingRouter.HandleFunc("/{id}/receipt", ReceiptHandler).Methods("GET")
+	parkingRouter.HandleFunc("", LimitBody(config.JSONMaxBytes(), utils.ErrBodyTooLarge, Idempotent(ReservationHandler))).Methods("POST")
 }
 
 // ReservationHandler reserver a parking spot
 func ReservationHandler(w http.ResponseWriter, r *http.Request) {
 	var request models.ParkingRequest
 
+	if fields := decodeStrict(r.Body, &request); fields != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(badRequestToJSON(fields))
+
+		return
+	}
+
+	id, err := usecases.MakeReservation(callerOf(r), request)
+	if err != nil {
+		if invalid, ok := err.(models.Invalid); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(validationToJSON(invalid, callerOf(r).Lot))
+
+			return
+		}
+		switch err {
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(errorToJSON(err, callerOf(r).Lot))
+		case utils.ErrLotFull:
+			w.WriteHeader(http.StatusConflict)
+			w.Write(errorToJSON(err, callerOf(r).Lot))
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(idToJSON(id))
+}
+
+// ImportHandler applies a batch of gate events recorded offline, answering with a result per event
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.ImportRequest
+
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -35,41 +82,55 @@ func ReservationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := usecases.MakeReservation(request)
+	results, err := usecases.Import(callerOf(r), request)
 	if err != nil {
+		if invalid, ok := err.(models.Invalid); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(validationToJSON(invalid, callerOf(r).Lot))
+
+			return
+		}
 		switch err {
 		case utils.ErrInternalServer:
 			w.WriteHeader(http.StatusInternalServerError)
-		case utils.ErrPlateNotValid:
-			w.WriteHeader(http.StatusBadRequest)
+			w.Write(stringToJSON(err.Error()))
 		}
-		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	json, err := json.Marshal(results)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(utils.ErrInternalServer.Error()))
 
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	w.Write(idToJSON(id))
+	w.Write(json)
 }
 
-// HistoryHandler gets the plate's history
+// HistoryHandler gets the plate's history in the caller's lot, or in every lot an admin sees with ?lots=all
 func HistoryHandler(w http.ResponseWriter, r *http.Request) {
 	request := models.ParkingRequest{}
 
 	vars := mux.Vars(r)
 	request.Plate = vars["plate"]
 
-	history, err := usecases.GetReservations(request)
+	history, err := usecases.GetReservations(callerOf(r), request, r.URL.Query().Get("lots") == "all")
 	if err != nil {
 		switch err {
 		case utils.ErrPlateNotValid:
 			w.WriteHeader(http.StatusBadRequest)
+		case utils.ErrForbidden:
+			w.WriteHeader(http.StatusForbidden)
 		case utils.ErrInternalServer:
 			w.WriteHeader(http.StatusInternalServerError)
 		case utils.ErrNotFound:
 			w.WriteHeader(http.StatusNotFound)
 		}
-		w.Write(stringToJSON(err.Error()))
+		w.Write(errorToJSON(err, callerOf(r).Lot))
 
 		return
 	}
@@ -86,13 +147,30 @@ func HistoryHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(json)
 }
 
-// PayHandler sets the payment
+// PayHandler sets the payment, the body may choose the payment method
 func PayHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	if err := usecases.Pay(vars["id"]); err != nil {
+	var request models.PaymentRequest
+	if r.Body != nil {
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+
+			return
+		}
+	}
+
+	if err := usecases.Pay(callerOf(r), vars["id"], request); err != nil {
+		if invalid, ok := err.(models.Invalid); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(validationToJSON(invalid, callerOf(r).Lot))
+
+			return
+		}
 		switch err {
-		case utils.ErrIDNotValid:
+		case utils.ErrIDNotValid, utils.ErrTicketNotValid:
 			w.WriteHeader(http.StatusBadRequest)
 		case utils.ErrInternalServer:
 			w.WriteHeader(http.StatusInternalServerError)
@@ -114,11 +192,11 @@ func PayHandler(w http.ResponseWriter, r *http.Request) {
 func CheckoutHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	if err := usecases.Checkout(vars["id"]); err != nil {
+	if err := usecases.Checkout(callerOf(r), vars["id"]); err != nil {
 		switch err {
 		case utils.ErrPayFirst:
 			w.WriteHeader(http.StatusPaymentRequired)
-		case utils.ErrIDNotValid:
+		case utils.ErrIDNotValid, utils.ErrTicketNotValid:
 			w.WriteHeader(http.StatusBadRequest)
 		case utils.ErrAlreadyCheckedOut:
 			w.WriteHeader(http.StatusOK)
@@ -136,65 +214,306 @@ func CheckoutHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(stringToJSON("Checked out"))
 }
 
-// ImageRecognitionHandler uploads an image and use recognition software
-func ImageRecognitionHandler(w http.ResponseWriter, r *http.Request) {
-	r.ParseMultipartForm(10 << 20)
+// TicketHandler renders the printable ticket, as PDF or as ESC/POS with ?format=escpos
+func TicketHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
 
-	file, _, err := r.FormFile("plate")
+	t, err := usecases.GetTicket(callerOf(r), vars["id"])
 	if err != nil {
-		logrus.Warn("Error getting plate image")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+		switch err {
+		case utils.ErrIDNotValid, utils.ErrTicketNotValid:
+			w.WriteHeader(http.StatusBadRequest)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		case utils.ErrNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write(stringToJSON(err.Error()))
 
 		return
 	}
-	defer file.Close()
 
-	tempFile, err := ioutil.TempFile("assets", "upload-*.png")
+	switch r.URL.Query().Get("format") {
+	case "", "pdf":
+		pdf, err := ticket.PDF(t)
+		if err != nil {
+			logrus.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(stringToJSON(utils.ErrInternalServer.Error()))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.WriteHeader(http.StatusOK)
+		w.Write(pdf)
+	case "escpos":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write(ticket.ESCPOS(t))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+	}
+}
+
+// ReceiptHandler renders the payment receipt, as PDF or as thermal printer text with ?format=text
+func ReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	rcpt, err := usecases.GetReceipt(callerOf(r), vars["id"])
 	if err != nil {
-		logrus.Warn("Error creating a temp file")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(stringToJSON(utils.ErrInternalServer.Error()))
+		switch err {
+		case utils.ErrIDNotValid, utils.ErrTicketNotValid:
+			w.WriteHeader(http.StatusBadRequest)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		case utils.ErrNotFound, utils.ErrNotPaid:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write(stringToJSON(err.Error()))
 
 		return
 	}
-	defer tempFile.Close()
 
-	fileBytes, err := ioutil.ReadAll(file)
-	if err != nil {
-		logrus.Warn("Error reading file")
+	switch r.URL.Query().Get("format") {
+	case "", "pdf":
+		pdf, err := receipt.PDF(rcpt)
+		if err != nil {
+			logrus.Warn(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(stringToJSON(utils.ErrInternalServer.Error()))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.WriteHeader(http.StatusOK)
+		w.Write(pdf)
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(receipt.Text(rcpt))
+	default:
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+	}
+}
+
+// ImageRecognitionHandler uploads an image and use recognition software
+func ImageRecognitionHandler(w http.ResponseWriter, r *http.Request) {
+	fileBytes, err := readUpload(r)
+	if err == nil {
+		err = images.Check(fileBytes, config.UploadMaxPixels())
+	}
+	if err != nil {
+		logrus.Warnf("Plate image refused: %s", err.Error())
+		switch err {
+		case utils.ErrImageTooLarge:
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		case utils.ErrImageNotSupported:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
 
+	if r.FormValue("multi") == "true" {
+		writeCheckinResults(w, callerOf(r), fileBytes, r.FormValue("camera"))
 		return
 	}
 
-	tempFile.Write(fileBytes)
+	result, err := usecases.CheckinImage(callerOf(r), fileBytes, r.FormValue("camera"))
+	if err != nil {
+		w.WriteHeader(checkinStatus(err))
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
 
-	request, err := usecases.Recognize(tempFile.Name())
+	json, err := json.Marshal(result)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(stringToJSON(utils.ErrImageRecognition.Error()))
+		w.Write(stringToJSON(utils.ErrInternalServer.Error()))
 
 		return
 	}
 
-	id, err := usecases.MakeReservation(request)
+	// Not read with confidence, nothing is checked in until an operator resolves the review
+	if result.Review != "" {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(json)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(json)
+}
+
+// ExitHandler checks a car out by the plate in a "plate" image, or in a JSON body, instead of its ticket id.
+// The gate opens on 200, on 402 the result has the amount due
+func ExitHandler(w http.ResponseWriter, r *http.Request) {
+	var result models.ExitResult
+	var err error
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var request models.ParkingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			if bodyTooLarge(err) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				w.Write(stringToJSON(utils.ErrImageTooLarge.Error()))
+
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+
+			return
+		}
+
+		result, err = usecases.Exit(callerOf(r), request.Plate)
+	} else {
+		var fileBytes []byte
+		fileBytes, err = readUpload(r)
+		if err == nil {
+			err = images.Check(fileBytes, config.UploadMaxPixels())
+		}
+		if err == nil {
+			result, err = usecases.ExitImage(callerOf(r), fileBytes, r.FormValue("camera"))
+		}
+	}
+
 	if err != nil {
 		switch err {
-		case utils.ErrInternalServer:
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write(stringToJSON(err.Error()))
-		case utils.ErrPlateNotValid:
+		case utils.ErrPlateNotValid, utils.ErrBadRequest:
 			w.WriteHeader(http.StatusBadRequest)
-			w.Write(stringToJSON(fmt.Sprintf("Text recognized `%s` is not in the right format: AAA-1234", request.Plate)))
+		case utils.ErrNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case utils.ErrSeveralOpen:
+			w.WriteHeader(http.StatusConflict)
+		case utils.ErrImageTooLarge:
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		case utils.ErrImageNotSupported:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		case utils.ErrImageRecognition:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
 		}
+		w.Write(errorToJSON(err, callerOf(r).Lot))
+
+		return
+	}
+
+	json, err := json.Marshal(result)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(utils.ErrInternalServer.Error()))
+
+		return
+	}
+
+	if !result.Open {
+		w.WriteHeader(http.StatusPaymentRequired)
+		w.Write(json)
 
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	w.Write(idToJSON(id))
+	w.Write(json)
+}
+
+// writeCheckinResults checks in every plate of a multi-lane image. The response is 200 when a ticket was created,
+// 202 when the plates only wait for review, and the status of the first error when none went through
+func writeCheckinResults(w http.ResponseWriter, caller models.Caller, fileBytes []byte, camera string) {
+	results, err := usecases.CheckinImages(caller, fileBytes, camera)
+	if err != nil {
+		w.WriteHeader(checkinStatus(err))
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	json, err := json.Marshal(results)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(utils.ErrInternalServer.Error()))
+
+		return
+	}
+
+	status := checkinStatus(results[0].Err)
+	for _, result := range results {
+		if result.ID != 0 {
+			status = http.StatusOK
+			break
+		}
+		if result.Review != "" {
+			status = http.StatusAccepted
+		}
+	}
+
+	w.WriteHeader(status)
+	w.Write(json)
+}
+
+// readUpload reads the "plate" file of a multipart form, or a raw image body with the camera in the query string
+func readUpload(r *http.Request) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, utils.ErrImageNotSupported
+	}
+
+	var body io.Reader
+	switch {
+	case mediaType == "multipart/form-data":
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			if bodyTooLarge(err) {
+				return nil, utils.ErrImageTooLarge
+			}
+			return nil, utils.ErrBadRequest
+		}
+
+		file, _, err := r.FormFile("plate")
+		if err != nil {
+			return nil, utils.ErrBadRequest
+		}
+		defer file.Close()
+		body = file
+	case strings.HasPrefix(mediaType, "image/"):
+		body = r.Body
+	default:
+		return nil, utils.ErrImageNotSupported
+	}
+
+	fileBytes, err := ioutil.ReadAll(body)
+	if err != nil {
+		if bodyTooLarge(err) {
+			return nil, utils.ErrImageTooLarge
+		}
+		return nil, utils.ErrBadRequest
+	}
+
+	return fileBytes, nil
+}
+
+// errorToJSON is the response of an error, plate errors list the formats the lot accepts
+func errorToJSON(err error, lot models.Lot) []byte {
+	return stringToJSON(errorMessage(err, lot))
+}
+
+func errorMessage(err error, lot models.Lot) string {
+	if err == utils.ErrPlateNotValid {
+		return fmt.Sprintf("%s, formats: %s", err.Error(), plates.ExamplesOf(lot.Formats()))
+	}
+
+	return err.Error()
 }
 
 func stringToJSON(str string) []byte {
@@ -218,3 +537,15 @@ func idToJSON(id uint) []byte {
 	bytes, _ := json.Marshal(res)
 	return bytes
 }
+
+// checkinStatus is the status of a plate that could not be checked in from an image
+func checkinStatus(err error) int {
+	if _, ok := err.(models.Invalid); ok {
+		return http.StatusBadRequest
+	}
+	if err == utils.ErrLotFull {
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
@@ -5,15 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"br.com.mlabs/api"
+	"br.com.mlabs/images"
 	"br.com.mlabs/models"
+	"br.com.mlabs/recognition"
 	"br.com.mlabs/storage"
+	"br.com.mlabs/ticket"
+	"br.com.mlabs/usecases"
+	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
@@ -21,15 +29,31 @@ import (
 
 var tx *gorm.DB
 
+// caller works in the default lot, like requests without an API key
+var caller models.Caller
+
 func TestMain(t *testing.M) {
 	storage.ConnectTest()
 	tx = storage.StartTest()
+	caller, _ = usecases.DefaultCaller()
 
 	t.Run()
 
 	tx.Exec("TRUNCATE parkings CASCADE;")
+	tx.Exec("TRUNCATE bookings;")
+	tx.Exec("TRUNCATE gate_events;")
+	tx.Exec("TRUNCATE idempotency_keys;")
+	tx.Exec("TRUNCATE reviews;")
 	tx.Exec("ALTER SEQUENCE parkings_id_seq RESTART WITH 1")
 	tx.Exec("ALTER SEQUENCE payments_id_seq RESTART WITH 1")
+	tx.Exec("ALTER SEQUENCE bookings_id_seq RESTART WITH 1")
+	tx.Exec("ALTER SEQUENCE receipts_id_seq RESTART WITH 1")
+	tx.Exec("UPDATE receipt_sequences SET last = 0")
+	tx.Exec("DELETE FROM users WHERE lot_id IN (SELECT id FROM lots WHERE name LIKE 'API %')")
+	tx.Exec("DELETE FROM receipt_sequences WHERE id IN (SELECT id FROM lots WHERE name LIKE 'API %')")
+	tx.Exec("DELETE FROM lot_categories WHERE lot_id IN (SELECT id FROM lots WHERE name LIKE 'API %')")
+	tx.Exec("DELETE FROM lots WHERE name LIKE 'API %'")
+	tx.Exec("DELETE FROM companies WHERE name LIKE 'API %'")
 }
 
 func TestReservationHappyPath(t *testing.T) {
@@ -61,7 +85,47 @@ func TestReservationValidationError(t *testing.T) {
 
 	assert.Equal(t, response.Code, http.StatusBadRequest)
 	bts, _ := ioutil.ReadAll(response.Body)
-	assert.Equal(t, string(bts), "{\"response\":\"Plate must be valid, format: AAA-1234\"}")
+	assert.Equal(t, string(bts), "{\"response\":\"Plate must be valid, formats: ABC-1234, ABC1D23\","+
+		"\"errors\":[{\"field\":\"plate\",\"rule\":\"plate\",\"value\":\"ABC-123\",\"message\":\"must be a plate, formats: ABC-1234, ABC1D23\"}]}")
+}
+
+func TestReservationUnknownField(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/parking", strings.NewReader(`{"plate":"ABC-1234","color":"red"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Bad request\","+
+		"\"errors\":[{\"field\":\"color\",\"rule\":\"unknown\",\"value\":null,\"message\":\"is not a known field\"}]}")
+}
+
+func TestReservationTrailingData(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/parking", strings.NewReader(`{"plate":"ABC-1234"}{"plate":"ABC-4321"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Bad request\","+
+		"\"errors\":[{\"field\":\"\",\"rule\":\"json\",\"value\":null,\"message\":\"must hold a single JSON value\"}]}")
+}
+
+func TestReservationNormalized(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/parking", strings.NewReader(`{"plate":"nrm 4321"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	// Found under its Mercosul form too
+	req, _ = http.NewRequest(http.MethodGet, "/parking/NRM4C21", nil)
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
 }
 
 func TestHistoryHappyPath(t *testing.T) {
@@ -103,13 +167,14 @@ func TestHistoryNotValid(t *testing.T) {
 
 	assert.Equal(t, response.Code, http.StatusBadRequest)
 	bts, _ := ioutil.ReadAll(response.Body)
-	assert.Equal(t, string(bts), "{\"response\":\"Plate must be valid, format: AAA-1234\"}")
+	assert.Equal(t, string(bts), "{\"response\":\"Plate must be valid, formats: ABC-1234, ABC1D23\"}")
 }
 
 // Tests checkin
 
 func TestPayHappyPath(t *testing.T) {
 	parking := models.Parking{
+		LotID:   caller.Lot.ID,
 		Checkin: time.Now(),
 		Plate:   "TST-1111",
 	}
@@ -129,12 +194,14 @@ func TestPayHappyPath(t *testing.T) {
 
 func TestPayAlreadyPaid(t *testing.T) {
 	parking := models.Parking{
+		LotID:   caller.Lot.ID,
 		Checkin: time.Now(),
 		Plate:   "TST-1111",
 	}
 	tx.Create(&parking)
 
 	payment := models.Payment{
+		LotID:     caller.Lot.ID,
 		Paid:      true,
 		ParkingID: parking.ID,
 	}
@@ -184,12 +251,14 @@ func TestPayBadRequest(t *testing.T) {
 
 func TestCheckoutHappyPath(t *testing.T) {
 	parking := models.Parking{
+		LotID:   caller.Lot.ID,
 		Checkin: time.Now(),
 		Plate:   "TST-1111",
 	}
 	tx.Create(&parking)
 
 	payment := models.Payment{
+		LotID:     caller.Lot.ID,
 		Paid:      true,
 		ParkingID: parking.ID,
 	}
@@ -209,12 +278,14 @@ func TestCheckoutHappyPath(t *testing.T) {
 
 func TestCheckoutAlreadyDone(t *testing.T) {
 	parking := models.Parking{
+		LotID:   caller.Lot.ID,
 		Checkin: time.Now(),
 		Plate:   "TST-1111",
 	}
 	tx.Create(&parking)
 
 	payment := models.Payment{
+		LotID:     caller.Lot.ID,
 		Paid:      true,
 		ParkingID: parking.ID,
 	}
@@ -236,6 +307,7 @@ func TestCheckoutAlreadyDone(t *testing.T) {
 
 func TestCheckoutPayPending(t *testing.T) {
 	parking := models.Parking{
+		LotID:   caller.Lot.ID,
 		Checkin: time.Now(),
 		Plate:   "TST-1111",
 	}
@@ -281,10 +353,542 @@ func TestCheckoutBadRequest(t *testing.T) {
 	assert.Equal(t, string(bts), "{\"response\":\"ID must be valid\"}")
 }
 
+// Tests ticket
+
+func TestTicketPDF(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Checkin: time.Now(),
+		Plate:   "TST-1111",
+	}
+	tx.Create(&parking)
+
+	url := fmt.Sprintf("/parking/%d/ticket", parking.ID)
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, response.Header().Get("Content-Type"), "application/pdf")
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.HasPrefix(string(bts), "%PDF"), true)
+}
+
+func TestTicketESCPOS(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Checkin: time.Now(),
+		Plate:   "TST-1111",
+	}
+	tx.Create(&parking)
+
+	url := fmt.Sprintf("/parking/%d/ticket?format=escpos", parking.ID)
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.Contains(string(bts), ticket.Token(parking.ID)), true)
+}
+
+func TestPayWithTicketToken(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Checkin: time.Now(),
+		Plate:   "TST-1111",
+	}
+	tx.Create(&parking)
+
+	url := fmt.Sprintf("/parking/%s/pay", ticket.Token(parking.ID))
+
+	req, _ := http.NewRequest(http.MethodPut, url, nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Paid\"}")
+}
+
+// Tests receipt
+
+func TestReceiptText(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Checkin: time.Now(),
+		Plate:   "RCP-1111",
+	}
+	tx.Create(&parking)
+
+	url := fmt.Sprintf("/parking/%d/pay", parking.ID)
+	req, _ := http.NewRequest(http.MethodPut, url, bytes.NewBufferString("{\"method\":\"pix\"}"))
+	req.Header.Set("Content-Type", "application/json")
+	executeRequest(req, api.NewParkingRouter)
+
+	url = fmt.Sprintf("/parking/%d/receipt?format=text", parking.ID)
+	req, _ = http.NewRequest(http.MethodGet, url, nil)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.Contains(string(bts), "RCP-1111"), true)
+	assert.Equal(t, strings.Contains(string(bts), "pix"), true)
+}
+
+func TestReceiptNotPaid(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Checkin: time.Now(),
+		Plate:   "RCP-1111",
+	}
+	tx.Create(&parking)
+
+	url := fmt.Sprintf("/parking/%d/receipt", parking.ID)
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusNotFound)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"There is no payment for this parking space\"}")
+}
+
+func TestPayMethodNotValid(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Checkin: time.Now(),
+		Plate:   "RCP-1111",
+	}
+	tx.Create(&parking)
+
+	url := fmt.Sprintf("/parking/%d/pay", parking.ID)
+	req, _ := http.NewRequest(http.MethodPut, url, bytes.NewBufferString("{\"method\":\"cheque\"}"))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Payment method must be one of: cash, credit, debit, pix\"}")
+}
+
+// Tests import
+
+func TestImportHappyPath(t *testing.T) {
+	body := fmt.Sprintf(`{"device_id":"gate-api","events":[{"event_id":"api-imp-%d","type":"checkin","time":"2020-11-10T10:00:00-03:00","plate":"IMP-4321"}]}`, time.Now().UnixNano())
+
+	req, _ := http.NewRequest(http.MethodPost, "/parking/import", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.Contains(string(bts), "\"status\":\"applied\""), true)
+}
+
+func TestImportNotValid(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/parking/import", bytes.NewBufferString(`{"device_id":"gate-api","events":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Import must have a device id and from 1 to 1000 events\"}")
+}
+
+// Tests idempotency
+
+func TestReservationIdempotent(t *testing.T) {
+	key := fmt.Sprintf("checkin-%d", time.Now().UnixNano())
+
+	send := func(plate string) *httptest.ResponseRecorder {
+		jsonBytes, _ := json.Marshal(models.ParkingRequest{Plate: plate})
+		req, _ := http.NewRequest(http.MethodPost, "/parking", bytes.NewBuffer(jsonBytes))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+
+		return executeRequest(req, api.NewParkingRouter)
+	}
+
+	first := send("IDM-1234")
+	assert.Equal(t, first.Code, http.StatusOK)
+	firstBody, _ := ioutil.ReadAll(first.Body)
+
+	replay := send("IDM-1234")
+	assert.Equal(t, replay.Code, http.StatusOK)
+	assert.Equal(t, replay.Header().Get("Idempotent-Replayed"), "true")
+	replayBody, _ := ioutil.ReadAll(replay.Body)
+	assert.Equal(t, replayBody, firstBody)
+
+	var parkings int64
+	tx.Model(&models.Parking{}).Where("plate = ?", "IDM-1234").Count(&parkings)
+	assert.Equal(t, parkings, int64(1))
+
+	reused := send("IDM-5678")
+	assert.Equal(t, reused.Code, http.StatusUnprocessableEntity)
+	bts, _ := ioutil.ReadAll(reused.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Idempotency key was used with a different request\"}")
+}
+
+func TestPayIdempotent(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Checkin: time.Now(),
+		Plate:   "IDM-1111",
+	}
+	tx.Create(&parking)
+
+	key := fmt.Sprintf("pay-%d", parking.ID)
+	url := fmt.Sprintf("/parking/%d/pay", parking.ID)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodPut, url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+
+		response := executeRequest(req, api.NewParkingRouter)
+
+		assert.Equal(t, response.Code, http.StatusOK)
+		bts, _ := ioutil.ReadAll(response.Body)
+		assert.Equal(t, string(bts), "{\"response\":\"Paid\"}")
+	}
+}
+
+func TestIdempotentTooLarge(t *testing.T) {
+	os.Setenv("JSON_MAX_BYTES", "100")
+	defer os.Unsetenv("JSON_MAX_BYTES")
+
+	body := fmt.Sprintf(`{"plate":"BIG-1234","image":"%s"}`, strings.Repeat("a", 200))
+	req, _ := http.NewRequest(http.MethodPost, "/parking", ioutil.NopCloser(bytes.NewBufferString(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", fmt.Sprintf("big-%d", time.Now().UnixNano()))
+	req.ContentLength = -1
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusRequestEntityTooLarge)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Request body is too large\"}")
+}
+
+func TestIdempotentPanic(t *testing.T) {
+	key := fmt.Sprintf("panic-%d", time.Now().UnixNano())
+	calls := 0
+	handler := api.Idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/parking", bytes.NewBufferString("{}"))
+		req.Header.Set("Idempotency-Key", key)
+		response := httptest.NewRecorder()
+		handlers.RecoveryHandler()(handler).ServeHTTP(response, req)
+
+		return response
+	}
+
+	assert.Equal(t, send().Code, http.StatusInternalServerError)
+
+	// The key was released, the retry runs instead of waiting for the lease
+	assert.Equal(t, send().Code, http.StatusOK)
+	assert.Equal(t, calls, 2)
+}
+
+func TestIdempotentLease(t *testing.T) {
+	key := fmt.Sprintf("lease-%d", time.Now().UnixNano())
+	_, claimed, err := storage.ClaimIdempotencyKey(key, "stalled", time.Millisecond)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, claimed, true)
+	time.Sleep(10 * time.Millisecond)
+
+	// The first request died without an answer, its claim is taken back once the lease runs out
+	_, claimed, err = storage.ClaimIdempotencyKey(key, "retry", time.Minute)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, claimed, true)
+
+	stored, claimed, err := storage.ClaimIdempotencyKey(key, "retry", time.Minute)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, claimed, false)
+	assert.Equal(t, stored.Status, 0)
+}
+
+// plateUpload is a multipart form with the image as its plate file
+func plateUpload(data []byte) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("plate", "plate.jpg")
+	part.Write(data)
+	form.Close()
+
+	return &body, form.FormDataContentType()
+}
+
+func TestImageRecognitionRawBody(t *testing.T) {
+	image, _ := ioutil.ReadFile("../assets/download2.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(image): recognition.Certain("RAW3R52"),
+	}))
+	defer usecases.SetRecognizer(nil)
+	dir, _ := ioutil.TempDir("", "uploads")
+	defer os.RemoveAll(dir)
+	usecases.SetImageStore(images.Local{Dir: dir})
+	defer usecases.SetImageStore(images.Local{Dir: "uploads"})
+
+	req, _ := http.NewRequest(http.MethodPost, "/parking/in?camera=gate-1", bytes.NewReader(image))
+	req.Header.Set("Content-Type", "image/jpeg")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.Contains(string(bts), "\"plate\":\"RAW3R52\""), true)
+}
+
+// plateReader reads the same plate on every region
+type plateReader string
+
+func (p plateReader) Recognize(image []byte) (models.Reading, error) {
+	return recognition.Certain(string(p)), nil
+}
+
+func TestImageRecognitionMulti(t *testing.T) {
+	usecases.SetRecognizer(plateReader("MUL3R52"))
+	defer usecases.SetRecognizer(nil)
+	dir, _ := ioutil.TempDir("", "uploads")
+	defer os.RemoveAll(dir)
+	usecases.SetImageStore(images.Local{Dir: dir})
+	defer usecases.SetImageStore(images.Local{Dir: "uploads"})
+
+	image, _ := ioutil.ReadFile("../assets/download.jpg")
+	body, contentType := plateUpload(image)
+	req, _ := http.NewRequest(http.MethodPost, "/parking/in?multi=true", body)
+	req.Header.Set("Content-Type", contentType)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	var results []models.CheckinResult
+	json.NewDecoder(response.Body).Decode(&results)
+	// Read in several regions, checked in once
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Plate, "MUL3R52")
+	assert.Greater(t, results[0].ID, uint(0))
+}
+
+func TestImageRecognitionMultiFull(t *testing.T) {
+	usecases.SetRecognizer(plateReader("MUL4R52"))
+	defer usecases.SetRecognizer(nil)
+	dir, _ := ioutil.TempDir("", "uploads")
+	defer os.RemoveAll(dir)
+	usecases.SetImageStore(images.Local{Dir: dir})
+	defer usecases.SetImageStore(images.Local{Dir: "uploads"})
+
+	// No space left, the plate is turned away as it would be alone
+	var parked, held int64
+	tx.Model(&models.Parking{}).Where("checkout IS NULL").Count(&parked)
+	tx.Model(&models.Booking{}).Where("status = ? AND starts_at <= now() AND ends_at > now()", models.BookingBooked).Count(&held)
+	os.Setenv("PARKING_CAPACITY", strconv.Itoa(int(parked+held)))
+	defer os.Unsetenv("PARKING_CAPACITY")
+
+	image, _ := ioutil.ReadFile("../assets/download.jpg")
+	body, contentType := plateUpload(image)
+	req, _ := http.NewRequest(http.MethodPost, "/parking/in?multi=true", body)
+	req.Header.Set("Content-Type", contentType)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusConflict)
+	var results []models.CheckinResult
+	json.NewDecoder(response.Body).Decode(&results)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].Error, "There are no spaces left")
+}
+
+func TestImageRecognitionTooLarge(t *testing.T) {
+	os.Setenv("UPLOAD_MAX_BYTES", "1000")
+	defer os.Unsetenv("UPLOAD_MAX_BYTES")
+
+	image, _ := ioutil.ReadFile("../assets/download.jpg")
+	body, contentType := plateUpload(image)
+	req, _ := http.NewRequest(http.MethodPost, "/parking/in", body)
+	req.Header.Set("Content-Type", contentType)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusRequestEntityTooLarge)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Image is too large\"}")
+
+	// Chunked bodies have no length, they are cut while read
+	body, contentType = plateUpload(image)
+	req, _ = http.NewRequest(http.MethodPost, "/parking/in", ioutil.NopCloser(body))
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = -1
+
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusRequestEntityTooLarge)
+
+	req, _ = http.NewRequest(http.MethodPost, "/parking/in", ioutil.NopCloser(bytes.NewReader(image)))
+	req.Header.Set("Content-Type", "image/jpeg")
+	req.Header.Set("Idempotency-Key", "too-large")
+	req.ContentLength = -1
+
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusRequestEntityTooLarge)
+}
+
+func TestImageRecognitionTooManyPixels(t *testing.T) {
+	os.Setenv("UPLOAD_MAX_PIXELS", "10000")
+	defer os.Unsetenv("UPLOAD_MAX_PIXELS")
+
+	image, _ := ioutil.ReadFile("../assets/download.jpg")
+	req, _ := http.NewRequest(http.MethodPost, "/parking/in", bytes.NewReader(image))
+	req.Header.Set("Content-Type", "image/jpeg")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusRequestEntityTooLarge)
+}
+
+func TestImageRecognitionNotSupported(t *testing.T) {
+	body, contentType := plateUpload([]byte("not an image"))
+	req, _ := http.NewRequest(http.MethodPost, "/parking/in", body)
+	req.Header.Set("Content-Type", contentType)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusUnsupportedMediaType)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Image must be JPEG, PNG or WebP\"}")
+
+	req, _ = http.NewRequest(http.MethodPost, "/parking/in", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusUnsupportedMediaType)
+
+	// Sniffed, not trusted from the header
+	req, _ = http.NewRequest(http.MethodPost, "/parking/in", strings.NewReader("GIF89a"))
+	req.Header.Set("Content-Type", "image/jpeg")
+
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusUnsupportedMediaType)
+}
+
+func TestImageRecognitionNoFile(t *testing.T) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("camera", "gate-1")
+	form.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "/parking/in", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+}
+
+func TestExitByPlate(t *testing.T) {
+	parking := models.Parking{LotID: caller.Lot.ID, Plate: "OUT-1234", Checkin: time.Now().Add(-30 * time.Minute)}
+	tx.Create(&parking)
+
+	send := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/parking/out", strings.NewReader(`{"plate":"OUT-1234"}`))
+		req.Header.Set("Content-Type", "application/json")
+		return executeRequest(req, api.NewParkingRouter)
+	}
+
+	response := send()
+	assert.Equal(t, response.Code, http.StatusPaymentRequired)
+	var result models.ExitResult
+	json.NewDecoder(response.Body).Decode(&result)
+	assert.Equal(t, result.ID, parking.ID)
+	assert.Equal(t, result.Open, false)
+	assert.Greater(t, result.AmountDue, int64(0))
+
+	tx.Create(&models.Payment{LotID: caller.Lot.ID, Paid: true, ParkingID: parking.ID})
+
+	response = send()
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), fmt.Sprintf("{\"id\":%d,\"plate\":\"OUT-1234\",\"open\":true,\"amount_due\":0}", parking.ID))
+
+	response = send()
+	assert.Equal(t, response.Code, http.StatusNotFound)
+}
+
+func TestExitByImage(t *testing.T) {
+	image, _ := ioutil.ReadFile("../assets/download2.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(image): recognition.Certain("OUT3R52"),
+	}))
+	defer usecases.SetRecognizer(nil)
+
+	parking := models.Parking{LotID: caller.Lot.ID, Plate: "OUT3R52", Checkin: time.Now()}
+	tx.Create(&parking)
+	tx.Create(&models.Payment{LotID: caller.Lot.ID, Paid: true, ParkingID: parking.ID})
+
+	body, contentType := plateUpload(image)
+	req, _ := http.NewRequest(http.MethodPost, "/parking/out", body)
+	req.Header.Set("Content-Type", contentType)
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	var result models.ExitResult
+	json.NewDecoder(response.Body).Decode(&result)
+	assert.Equal(t, result.Open, true)
+	assert.Equal(t, result.Recognition.Plate, "OUT3R52")
+
+	// Unknown to the engine
+	blurry, _ := ioutil.ReadFile("../assets/download.jpg")
+	req, _ = http.NewRequest(http.MethodPost, "/parking/out", bytes.NewReader(blurry))
+	req.Header.Set("Content-Type", "image/jpeg")
+
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusUnprocessableEntity)
+}
+
+func TestExitNotValid(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/parking/out", strings.NewReader(`{"plate":"OUT-12"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	response := executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+
+	tx.Create(&models.Parking{LotID: caller.Lot.ID, Plate: "OUT-5678", Checkin: time.Now()})
+	tx.Create(&models.Parking{LotID: caller.Lot.ID, Plate: "OUT-5678", Checkin: time.Now()})
+	req, _ = http.NewRequest(http.MethodPost, "/parking/out", strings.NewReader(`{"plate":"OUT-5678"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	response = executeRequest(req, api.NewParkingRouter)
+
+	assert.Equal(t, response.Code, http.StatusConflict)
+}
+
 func executeRequest(req *http.Request, subRouter func(router *mux.Router)) *httptest.ResponseRecorder {
 	responseRecorder := httptest.NewRecorder()
 
 	router := mux.NewRouter()
+	router.Use(api.Authenticate)
 	subRouter(router)
 
 	router.ServeHTTP(responseRecorder, req)
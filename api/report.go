@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"br.com.mlabs/reports"
+	"br.com.mlabs/utils"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// NewReportRouter creates a subrouter for report endpoints
+func NewReportRouter(router *mux.Router) {
+	reportRouter := router.PathPrefix("/reports").Subrouter()
+	reportRouter.HandleFunc("/revenue", RevenueHandler).Methods("GET")
+	reportRouter.HandleFunc("/movements", MovementsHandler).Methods("GET")
+	reportRouter.HandleFunc("/categories", CategoriesHandler).Methods("GET")
+	reportRouter.HandleFunc("/analytics", AnalyticsHandler).Methods("GET")
+}
+
+// RevenueHandler reports the revenue by day and payment method
+func RevenueHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	rng, err := reports.ParseRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	revenue, err := reports.GetRevenue(callerOf(r).Lot, rng)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	writeReport(w, r, "revenue", rng, revenue)
+}
+
+// MovementsHandler reports entries, exits and open tickets by day
+func MovementsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	rng, err := reports.ParseRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	movements, err := reports.GetMovements(callerOf(r).Lot, rng)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	writeReport(w, r, "movements", rng, movements)
+}
+
+// CategoriesHandler reports entries, exits and revenue by day and vehicle category
+func CategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	rng, err := reports.ParseRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	categories, err := reports.GetCategories(callerOf(r).Lot, rng)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	writeReport(w, r, "categories", rng, categories)
+}
+
+// writeReport writes a report as JSON, or as a CSV download with ?format=csv
+func writeReport(w http.ResponseWriter, r *http.Request, name string, rng reports.Range, table reports.Table) {
+	var err error
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = reports.WriteJSON(w, table)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s-%s.csv", name, rng.From, rng.To))
+		w.WriteHeader(http.StatusOK)
+		err = reports.WriteCSV(w, table)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+	}
+
+	if err != nil {
+		logrus.Warn(err.Error())
+	}
+}
+
+// AnalyticsHandler reports dwell times, the hour of week heatmap, visitors and turnover
+func AnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	rng, err := reports.ParseRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	analytics, err := reports.GetAnalytics(callerOf(r).Lot, rng)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	json, err := json.Marshal(analytics)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(utils.ErrInternalServer.Error()))
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(json)
+}
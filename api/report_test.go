@@ -0,0 +1,62 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"br.com.mlabs/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevenueCSV(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/reports/revenue?from=2020-11-01&to=2020-11-30&format=csv", nil)
+
+	response := executeRequest(req, api.NewReportRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, response.Header().Get("Content-Disposition"), "attachment; filename=revenue-2020-11-01-2020-11-30.csv")
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.HasPrefix(string(bts), "day,lot,method,payments,amount\n"), true)
+}
+
+func TestMovementsJSON(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/reports/movements?from=2020-11-01&to=2020-11-02", nil)
+
+	response := executeRequest(req, api.NewReportRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.HasPrefix(string(bts), "[{\"day\":\"2020-11-01\","), true)
+}
+
+func TestCategoriesCSV(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/reports/categories?from=2020-11-01&to=2020-11-01&format=csv", nil)
+
+	response := executeRequest(req, api.NewReportRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.HasPrefix(string(bts), "day,lot,category,entries,exits,payments,amount\n2020-11-01,Parking,motorcycle,"), true)
+}
+
+func TestReportRangeNotValid(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/reports/revenue?from=2020-11-30&to=2020-11-01", nil)
+
+	response := executeRequest(req, api.NewReportRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Date range must be valid, format: YYYY-MM-DD, up to 366 days\"}")
+}
+
+func TestAnalytics(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/reports/analytics?from=2020-11-01&to=2020-11-07", nil)
+
+	response := executeRequest(req, api.NewReportRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.HasPrefix(string(bts), "{\"from\":\"2020-11-01\",\"to\":\"2020-11-07\",\"dwell\":"), true)
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/gorilla/mux"
+)
+
+// NewReviewRouter creates a subrouter for the image check-ins waiting for an operator
+func NewReviewRouter(router *mux.Router) {
+	reviewRouter := router.PathPrefix("/review").Subrouter()
+	reviewRouter.HandleFunc("", ReviewListHandler).Methods("GET")
+	reviewRouter.HandleFunc("/{token}", ReviewHandler).Methods("GET")
+	reviewRouter.HandleFunc("/{token}/image", ReviewImageHandler).Methods("GET")
+	reviewRouter.HandleFunc("/{token}/accept", ReviewAcceptHandler).Methods("PUT")
+	reviewRouter.HandleFunc("/{token}/edit", ReviewEditHandler).Methods("PUT")
+	reviewRouter.HandleFunc("/{token}/reject", ReviewRejectHandler).Methods("PUT")
+}
+
+// ReviewListHandler lists reviews, pending unless the status query parameter says otherwise
+func ReviewListHandler(w http.ResponseWriter, r *http.Request) {
+	reviews, err := usecases.GetReviews(callerOf(r), r.URL.Query().Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	writeJSON(w, reviews)
+}
+
+// ReviewHandler shows a review with its candidates
+func ReviewHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	review, err := usecases.GetReview(callerOf(r), vars["token"])
+	if err != nil {
+		switch err {
+		case utils.ErrNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	writeJSON(w, review)
+}
+
+// ReviewImageHandler shows the image a review was made from
+func ReviewImageHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	image, err := usecases.ReviewImage(callerOf(r), vars["token"])
+	if err != nil {
+		switch err {
+		case utils.ErrNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write(stringToJSON(err.Error()))
+
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(image))
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+// ReviewAcceptHandler checks in the best reading of a review
+func ReviewAcceptHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	id, err := usecases.AcceptReview(callerOf(r), vars["token"])
+	writeReviewResult(w, r, id, err)
+}
+
+// ReviewEditHandler checks in the plate in the body instead of the reading
+func ReviewEditHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var request models.ReviewRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(stringToJSON(utils.ErrBadRequest.Error()))
+
+		return
+	}
+
+	id, err := usecases.EditReview(callerOf(r), vars["token"], request)
+	writeReviewResult(w, r, id, err)
+}
+
+// ReviewRejectHandler closes a review without a ticket
+func ReviewRejectHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	err := usecases.RejectReview(callerOf(r), vars["token"])
+	if err != nil {
+		writeReviewResult(w, r, 0, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(stringToJSON("Rejected"))
+}
+
+// writeReviewResult writes the ticket created by resolving a review, or why it could not be resolved
+func writeReviewResult(w http.ResponseWriter, r *http.Request, id uint, err error) {
+	if err != nil {
+		switch err {
+		case utils.ErrPlateNotValid:
+			w.WriteHeader(http.StatusBadRequest)
+		case utils.ErrNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case utils.ErrReviewClosed, utils.ErrLotFull:
+			w.WriteHeader(http.StatusConflict)
+		case utils.ErrInternalServer:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write(errorToJSON(err, callerOf(r).Lot))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(idToJSON(id))
+}
+
+// writeJSON writes a value as a JSON response
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	json, err := json.Marshal(value)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(stringToJSON(utils.ErrInternalServer.Error()))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(json)
+}
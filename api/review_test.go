@@ -0,0 +1,175 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"br.com.mlabs/api"
+	"br.com.mlabs/images"
+	"br.com.mlabs/models"
+	"br.com.mlabs/usecases"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewList(t *testing.T) {
+	review := models.Review{
+		LotID:      caller.Lot.ID,
+		Token:      "list-token",
+		Plate:      "LST-4321",
+		Candidates: `[{"plate":"LST-4321","confidence":41,"valid":true,"box":{"x":0,"y":0,"width":10,"height":5}}]`,
+		Status:     models.ReviewPending,
+		CapturedAt: time.Now(),
+	}
+	tx.Create(&review)
+
+	req, _ := http.NewRequest(http.MethodGet, "/review", nil)
+	response := executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.Contains(string(bts), `"token":"list-token","status":"pending","plate":"LST-4321","confidence":0,"candidates":[{"plate":"LST-4321","confidence":41`), true)
+
+	req, _ = http.NewRequest(http.MethodGet, "/review/list-token", nil)
+	response = executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	req, _ = http.NewRequest(http.MethodGet, "/review/unknown", nil)
+	response = executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusNotFound)
+}
+
+func TestReviewImage(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "uploads")
+	defer os.RemoveAll(dir)
+	store := images.Local{Dir: dir}
+	usecases.SetImageStore(store)
+	defer usecases.SetImageStore(images.Local{Dir: "uploads"})
+
+	image, _ := ioutil.ReadFile("../assets/download2.jpg")
+	store.Put(images.Key(image), image)
+
+	review := models.Review{
+		LotID:      caller.Lot.ID,
+		Token:      "image-token",
+		Image:      images.Key(image),
+		Status:     models.ReviewPending,
+		CapturedAt: time.Now(),
+	}
+	tx.Create(&review)
+
+	req, _ := http.NewRequest(http.MethodGet, "/review/image-token/image", nil)
+	response := executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	assert.Equal(t, response.Header().Get("Content-Type"), "image/jpeg")
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, bts, image)
+
+	// Purged images are gone
+	tx.Model(&review).Update("image", "")
+	req, _ = http.NewRequest(http.MethodGet, "/review/image-token/image", nil)
+	response = executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusNotFound)
+}
+
+func TestReviewAccept(t *testing.T) {
+	capturedAt := time.Now().Add(-5 * time.Minute).Truncate(time.Second)
+	review := models.Review{
+		LotID:      caller.Lot.ID,
+		Token:      "accept-token",
+		Plate:      "REV-1234",
+		Status:     models.ReviewPending,
+		CapturedAt: capturedAt,
+	}
+	tx.Create(&review)
+
+	req, _ := http.NewRequest(http.MethodPut, "/review/accept-token/accept", nil)
+	response := executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+	bts, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, strings.Contains(string(bts), "{\"id\":"), true)
+
+	// The ticket starts when the car arrived, not when the operator answered
+	var parking models.Parking
+	tx.Where("plate = ?", "REV-1234").First(&parking)
+	assert.Equal(t, parking.Checkin.Equal(capturedAt), true)
+
+	req, _ = http.NewRequest(http.MethodGet, "/review/accept-token", nil)
+	response = executeRequest(req, api.NewReviewRouter)
+
+	var entry models.ReviewEntry
+	json.NewDecoder(response.Body).Decode(&entry)
+	assert.Equal(t, entry.Status, models.ReviewAccepted)
+	assert.Equal(t, *entry.ParkingID, parking.ID)
+	assert.GreaterOrEqual(t, entry.ResolutionSeconds, int64(300))
+
+	req, _ = http.NewRequest(http.MethodPut, "/review/accept-token/accept", nil)
+	response = executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusConflict)
+	bts, _ = ioutil.ReadAll(response.Body)
+	assert.Equal(t, string(bts), "{\"response\":\"Review was already resolved\"}")
+}
+
+func TestReviewEdit(t *testing.T) {
+	review := models.Review{
+		LotID:      caller.Lot.ID,
+		Token:      "edit-token",
+		Plate:      "SPGUARULHOS",
+		Status:     models.ReviewPending,
+		CapturedAt: time.Now(),
+	}
+	tx.Create(&review)
+
+	req, _ := http.NewRequest(http.MethodPut, "/review/edit-token/accept", nil)
+	response := executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusBadRequest)
+
+	jsonBytes, _ := json.Marshal(models.ReviewRequest{Plate: "REV1B34"})
+	req, _ = http.NewRequest(http.MethodPut, "/review/edit-token/edit", bytes.NewBuffer(jsonBytes))
+	response = executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	var parking models.Parking
+	tx.Where("plate = ?", "REV1B34").First(&parking)
+	tx.First(&review, review.ID)
+	assert.Equal(t, review.Status, models.ReviewEdited)
+	assert.Equal(t, *review.ParkingID, parking.ID)
+}
+
+func TestReviewReject(t *testing.T) {
+	review := models.Review{
+		LotID:      caller.Lot.ID,
+		Token:      "reject-token",
+		Status:     models.ReviewPending,
+		CapturedAt: time.Now(),
+	}
+	tx.Create(&review)
+
+	req, _ := http.NewRequest(http.MethodPut, "/review/reject-token/reject", nil)
+	response := executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusOK)
+
+	tx.First(&review, review.ID)
+	assert.Equal(t, review.Status, models.ReviewRejected)
+	assert.Equal(t, review.ParkingID, (*uint)(nil))
+	assert.NotEqual(t, review.ResolvedAt, (*time.Time)(nil))
+
+	req, _ = http.NewRequest(http.MethodPut, "/review/unknown/reject", nil)
+	response = executeRequest(req, api.NewReviewRouter)
+
+	assert.Equal(t, response.Code, http.StatusNotFound)
+}
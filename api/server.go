@@ -14,7 +14,13 @@ func Start() {
 	router := mux.NewRouter()
 	router.MethodNotAllowedHandler = http.HandlerFunc(MethodNotAllowedHandler)
 	router.NotFoundHandler = http.HandlerFunc(NotFoundHandler)
+	router.Use(Authenticate)
 	NewParkingRouter(router)
+	NewBookingRouter(router)
+	NewReportRouter(router)
+	NewExportRouter(router)
+	NewReviewRouter(router)
+	NewLotRouter(router)
 
 	recoveryRouter := handlers.RecoveryHandler()(router)
 
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+)
+
+// decodeStrict reads a single JSON value into v, refusing fields v does not have and anything after the value
+func decodeStrict(body io.Reader, v interface{}) []models.FieldError {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+			return []models.FieldError{{
+				Field:   strings.Trim(field, "\""),
+				Rule:    "unknown",
+				Message: "is not a known field",
+			}}
+		}
+		return []models.FieldError{{Rule: "json", Message: err.Error()}}
+	}
+
+	var extra json.RawMessage
+	if err := decoder.Decode(&extra); err != io.EOF {
+		return []models.FieldError{{Rule: "json", Message: "must hold a single JSON value"}}
+	}
+
+	return nil
+}
+
+// validationToJSON is the response of a validation error, with the fields of the request that failed
+func validationToJSON(invalid models.Invalid, lot models.Lot) []byte {
+	return fieldsToJSON(errorMessage(invalid.Err, lot), invalid.Fields)
+}
+
+// badRequestToJSON is the response of a body that could not be read
+func badRequestToJSON(fields []models.FieldError) []byte {
+	return fieldsToJSON(utils.ErrBadRequest.Error(), fields)
+}
+
+func fieldsToJSON(message string, fields []models.FieldError) []byte {
+	res := struct {
+		Response string              `json:"response"`
+		Errors   []models.FieldError `json:"errors,omitempty"`
+	}{
+		Response: message,
+		Errors:   fields,
+	}
+
+	bytes, _ := json.Marshal(res)
+	return bytes
+}
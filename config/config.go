@@ -0,0 +1,345 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"br.com.mlabs/images"
+	"br.com.mlabs/imaging"
+	"br.com.mlabs/models"
+	"br.com.mlabs/plates"
+	"br.com.mlabs/stream"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	secret     []byte
+	secretOnce sync.Once
+
+	cameras     map[string]imaging.Options
+	camerasOnce sync.Once
+
+	streams     map[string]stream.Camera
+	streamsOnce sync.Once
+)
+
+// Capacity is the number of spaces in the lot, zero means unlimited
+func Capacity() int {
+	return intEnv("PARKING_CAPACITY", 0)
+}
+
+// Capacities is the number of spaces in the lot, PARKING_CAPACITY in total and PARKING_CAPACITY_<CATEGORY>
+// for each vehicle category, like PARKING_CAPACITY_MOTORCYCLE, zero means unlimited
+func Capacities() models.Capacity {
+	capacity := models.Capacity{Total: Capacity(), Categories: map[string]int{}}
+	for _, category := range models.Categories {
+		capacity.Categories[category] = intEnv("PARKING_CAPACITY_"+strings.ToUpper(category), 0)
+	}
+
+	return capacity
+}
+
+// NoShowGrace is how long a booking is held after its start before it expires
+func NoShowGrace() time.Duration {
+	return durationEnv("BOOKING_NO_SHOW_GRACE", 15*time.Minute)
+}
+
+// BookingExpiryInterval is how often the no-show expiry job runs
+func BookingExpiryInterval() time.Duration {
+	return durationEnv("BOOKING_EXPIRY_INTERVAL", time.Minute)
+}
+
+// Tariff is the price table, amounts in cents
+func Tariff() models.Tariff {
+	return models.Tariff{
+		Grace:          durationEnv("TARIFF_GRACE", 0),
+		FirstHour:      int64(intEnv("TARIFF_FIRST_HOUR", 1000)),
+		AdditionalHour: int64(intEnv("TARIFF_ADDITIONAL_HOUR", 500)),
+		DailyMax:       int64(intEnv("TARIFF_DAILY_MAX", 0)),
+	}
+}
+
+// CategoryTariff is the price table of a vehicle category, TARIFF_<CATEGORY>_* variables like
+// TARIFF_TRUCK_FIRST_HOUR override the lot's tariff
+func CategoryTariff(category string) models.Tariff {
+	tariff := Tariff()
+	prefix := "TARIFF_" + strings.ToUpper(category) + "_"
+
+	return models.Tariff{
+		Grace:          durationEnv(prefix+"GRACE", tariff.Grace),
+		FirstHour:      int64(intEnv(prefix+"FIRST_HOUR", int(tariff.FirstHour))),
+		AdditionalHour: int64(intEnv(prefix+"ADDITIONAL_HOUR", int(tariff.AdditionalHour))),
+		DailyMax:       int64(intEnv(prefix+"DAILY_MAX", int(tariff.DailyMax))),
+	}
+}
+
+// IdempotencyTTL is how long responses to requests with an Idempotency-Key are kept
+func IdempotencyTTL() time.Duration {
+	return durationEnv("IDEMPOTENCY_TTL", 24*time.Hour)
+}
+
+// IdempotencyLease is how long a request with an Idempotency-Key holds it before a retry may take it back,
+// it must outlast the slowest request
+func IdempotencyLease() time.Duration {
+	return durationEnv("IDEMPOTENCY_LEASE", time.Minute)
+}
+
+// UploadMaxBytes is the largest image upload request, in bytes
+func UploadMaxBytes() int64 {
+	return int64(intEnv("UPLOAD_MAX_BYTES", 10<<20))
+}
+
+// JSONMaxBytes is the largest JSON request body, in bytes
+func JSONMaxBytes() int64 {
+	return int64(intEnv("JSON_MAX_BYTES", 1<<20))
+}
+
+// UploadMaxPixels is the largest image accepted, width times height, zero means unlimited
+func UploadMaxPixels() int {
+	return intEnv("UPLOAD_MAX_PIXELS", 40000000)
+}
+
+// OCREngine is the plate recognition engine, tesseract or fake
+func OCREngine() string {
+	return stringEnv("OCR_ENGINE", "tesseract")
+}
+
+// OCRFakePlates is the JSON file of image hash to plate read by the fake engine
+func OCRFakePlates() string {
+	return stringEnv("OCR_FAKE_PLATES", "")
+}
+
+// OCRMinConfidence is the reading confidence, from 0 to 100, below which an image check-in waits for an operator
+func OCRMinConfidence() float64 {
+	return float64(intEnv("OCR_MIN_CONFIDENCE", 60))
+}
+
+// OCRDebug saves every preprocessing stage in OCRDebugDir
+func OCRDebug() bool {
+	return boolEnv("OCR_DEBUG", false)
+}
+
+// OCRDebugDir is where preprocessing stages are saved in debug mode
+func OCRDebugDir() string {
+	return stringEnv("OCR_DEBUG_DIR", "debug")
+}
+
+// Camera is the preprocessing of a camera's images, read from the CAMERAS JSON file, unknown cameras use its "default" entry
+func Camera(id string) imaging.Options {
+	camerasOnce.Do(func() {
+		cameras = map[string]imaging.Options{}
+
+		path := stringEnv("CAMERAS", "")
+		if path == "" {
+			return
+		}
+
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			logrus.Warnf("CAMERAS could not be read, images will not be preprocessed: %s", err.Error())
+			return
+		}
+		if err := json.Unmarshal(bytes, &cameras); err != nil {
+			logrus.Warnf("CAMERAS is not valid JSON, images will not be preprocessed: %s", err.Error())
+		}
+	})
+
+	if options, ok := cameras[id]; ok {
+		return options
+	}
+
+	return cameras["default"]
+}
+
+// Streams is the MJPEG cameras watched for plates, read from the STREAMS JSON file, keyed by camera name
+func Streams() map[string]stream.Camera {
+	streamsOnce.Do(func() {
+		streams = map[string]stream.Camera{}
+
+		path := stringEnv("STREAMS", "")
+		if path == "" {
+			return
+		}
+
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			logrus.Warnf("STREAMS could not be read, no camera will be watched: %s", err.Error())
+			return
+		}
+		if err := json.Unmarshal(bytes, &streams); err != nil {
+			logrus.Warnf("STREAMS is not valid JSON, no camera will be watched: %s", err.Error())
+		}
+	})
+
+	return streams
+}
+
+// PlateFormats is the plate formats the lot accepts, PLATE_FORMATS lists their ids separated by commas, in the order ties are broken
+func PlateFormats() []plates.Format {
+	ids := strings.Split(stringEnv("PLATE_FORMATS", strings.Join(plates.Default, ",")), ",")
+
+	var formats []plates.Format
+	for _, id := range ids {
+		format, ok := plates.Get(strings.TrimSpace(id))
+		if !ok {
+			logrus.Warnf("PLATE_FORMATS has an unknown format %q, it was left out", id)
+			continue
+		}
+		formats = append(formats, format)
+	}
+
+	if len(formats) == 0 {
+		logrus.Warnf("PLATE_FORMATS has no known format, using %s", strings.Join(plates.Default, ","))
+		for _, id := range plates.Default {
+			format, _ := plates.Get(id)
+			formats = append(formats, format)
+		}
+	}
+
+	return formats
+}
+
+// ImageStore is where uploaded images are kept, local or s3
+func ImageStore() string {
+	return stringEnv("IMAGE_STORE", "local")
+}
+
+// ImageDir is the directory of the local image store
+func ImageDir() string {
+	return stringEnv("IMAGE_DIR", "uploads")
+}
+
+// S3 is the bucket of the s3 image store, the endpoint may point to MinIO or any S3 compatible service
+func S3() images.S3 {
+	return images.S3{
+		Endpoint:  stringEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		Bucket:    stringEnv("S3_BUCKET", ""),
+		Region:    stringEnv("S3_REGION", "us-east-1"),
+		AccessKey: stringEnv("S3_ACCESS_KEY", ""),
+		SecretKey: stringEnv("S3_SECRET_KEY", ""),
+		Timeout:   durationEnv("S3_TIMEOUT", images.DefaultS3Timeout),
+	}
+}
+
+// ImageRetentionDays is how long images of tickets and resolved reviews are kept, zero keeps them forever
+func ImageRetentionDays() int {
+	return intEnv("IMAGE_RETENTION_DAYS", 30)
+}
+
+// LotName is the name of the default lot, used by requests without an API key and printed on its tickets
+func LotName() string {
+	return stringEnv("LOT_NAME", "Parking")
+}
+
+// APIKeysRequired refuses requests without an API key instead of working in the default lot
+func APIKeysRequired() bool {
+	return boolEnv("API_KEYS_REQUIRED", false)
+}
+
+// LotCapacity is the number of spaces of a lot, what the lot does not set comes from Capacities
+func LotCapacity(lot models.Lot) models.Capacity {
+	capacity := Capacities()
+	if lot.Capacity != nil {
+		capacity.Total = *lot.Capacity
+	}
+	for _, category := range lot.Categories {
+		capacity.Categories[category.Category] = category.Spaces
+	}
+
+	return capacity
+}
+
+// LotTariff is the price table of a vehicle category in a lot, CategoryTariff when the lot does not set it
+func LotTariff(lot models.Lot, category string) models.Tariff {
+	for _, c := range lot.Categories {
+		if c.Category == category {
+			return c.Tariff
+		}
+	}
+
+	return CategoryTariff(category)
+}
+
+// TicketSecret is the key used to sign ticket tokens
+func TicketSecret() []byte {
+	secretOnce.Do(func() {
+		value, ok := os.LookupEnv("TICKET_SECRET")
+		if ok && value != "" {
+			secret = []byte(value)
+			return
+		}
+
+		logrus.Warn("TICKET_SECRET not found, tickets will not be valid after a restart or in other replicas")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logrus.Panic(err.Error())
+		}
+	})
+
+	return secret
+}
+
+// Timezone is where report days start and end
+func Timezone() string {
+	return stringEnv("REPORT_TIMEZONE", "America/Sao_Paulo")
+}
+
+func stringEnv(key string, fallback string) string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	return value
+}
+
+func intEnv(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	res, err := strconv.Atoi(value)
+	if err != nil {
+		logrus.Warnf("%s is not a number, using %d", key, fallback)
+		return fallback
+	}
+
+	return res
+}
+
+func boolEnv(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	res, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warnf("%s is not a boolean, using %t", key, fallback)
+		return fallback
+	}
+
+	return res
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	res, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.Warnf("%s is not a duration, using %s", key, fallback)
+		return fallback
+	}
+
+	return res
+}
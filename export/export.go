@@ -0,0 +1,243 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/models"
+	"br.com.mlabs/reports"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+)
+
+// Export formats
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// PageSize is how many tickets are read from the database at a time
+const PageSize = 1000
+
+// Options chooses what is exported and how
+type Options struct {
+	Range   reports.Range
+	Format  string
+	Columns []string
+	Gzip    bool
+}
+
+// Flusher pushes what was written so far to the client
+type Flusher interface {
+	Flush()
+}
+
+// cents is an amount written in reais, as a decimal in both CSV and NDJSON
+type cents int64
+
+func (c cents) String() string {
+	sign := ""
+	if c < 0 {
+		sign = "-"
+		c = -c
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+// MarshalJSON writes the amount as a JSON number in reais, like the CSV column
+func (c cents) MarshalJSON() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+type column func(row models.ExportRow, loc *time.Location) interface{}
+
+var columns = map[string]column{
+	"id":       func(row models.ExportRow, loc *time.Location) interface{} { return row.ID },
+	"plate":    func(row models.ExportRow, loc *time.Location) interface{} { return row.Plate },
+	"checkin":  func(row models.ExportRow, loc *time.Location) interface{} { return row.Checkin.In(loc) },
+	"checkout": func(row models.ExportRow, loc *time.Location) interface{} { return in(row.Checkout, loc) },
+	"prepaid":  func(row models.ExportRow, loc *time.Location) interface{} { return cents(row.Prepaid) },
+	"paid":     func(row models.ExportRow, loc *time.Location) interface{} { return row.Paid },
+	"amount":   func(row models.ExportRow, loc *time.Location) interface{} { return cents(row.Amount) },
+	"method":   func(row models.ExportRow, loc *time.Location) interface{} { return row.Method },
+	"paid_at":  func(row models.ExportRow, loc *time.Location) interface{} { return in(row.PaidAt, loc) },
+}
+
+// DefaultColumns are exported when no column is chosen
+var DefaultColumns = []string{"id", "plate", "checkin", "checkout", "prepaid", "paid", "amount", "method", "paid_at"}
+
+// ParseOptions validates the export format and the comma separated columns
+func ParseOptions(rng reports.Range, format string, cols string, gz bool) (Options, error) {
+	if format == "" {
+		format = CSV
+	}
+	if format != CSV && format != NDJSON {
+		return Options{}, utils.ErrExportNotValid
+	}
+
+	selected := DefaultColumns
+	if cols != "" {
+		selected = strings.Split(cols, ",")
+	}
+	for _, col := range selected {
+		if _, ok := columns[col]; !ok {
+			return Options{}, utils.ErrExportNotValid
+		}
+	}
+
+	return Options{Range: rng, Format: format, Columns: selected, Gzip: gz}, nil
+}
+
+// Filename is the name of the exported file
+func (o Options) Filename() string {
+	name := fmt.Sprintf("tickets-%s-%s.%s", o.Range.From, o.Range.To, o.Format)
+	if o.Gzip {
+		name += ".gz"
+	}
+
+	return name
+}
+
+// Export streams the tickets and payments of a lot page by page, so memory does not grow with the range
+func Export(w io.Writer, lotID uint, o Options) error {
+	loc, err := time.LoadLocation(config.Timezone())
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(Flusher)
+	if o.Gzip {
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		w = gz
+	}
+
+	enc := newEncoder(w, o)
+	if err := enc.header(); err != nil {
+		return err
+	}
+
+	var last uint
+	for {
+		rows, err := storage.ExportPage(lotID, o.Range.From, o.Range.To, config.Timezone(), last, PageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := enc.row(row, loc); err != nil {
+				return err
+			}
+		}
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		if gz, ok := w.(*gzip.Writer); ok {
+			gz.Flush()
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(rows) < PageSize {
+			return nil
+		}
+		last = rows[len(rows)-1].ID
+	}
+}
+
+type encoder struct {
+	w       io.Writer
+	csv     *csv.Writer
+	columns []string
+}
+
+func newEncoder(w io.Writer, o Options) *encoder {
+	enc := &encoder{w: w, columns: o.Columns}
+	if o.Format == CSV {
+		enc.csv = csv.NewWriter(w)
+	}
+
+	return enc
+}
+
+func (e *encoder) header() error {
+	if e.csv == nil {
+		return nil
+	}
+
+	return e.csv.Write(e.columns)
+}
+
+func (e *encoder) row(row models.ExportRow, loc *time.Location) error {
+	if e.csv != nil {
+		record := make([]string, len(e.columns))
+		for i, col := range e.columns {
+			record[i] = csvValue(columns[col](row, loc))
+		}
+
+		return e.csv.Write(record)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, col := range e.columns {
+		value, err := json.Marshal(columns[col](row, loc))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, "%q:%s", col, value)
+	}
+	buf.WriteString("}\n")
+
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func (e *encoder) flush() error {
+	if e.csv == nil {
+		return nil
+	}
+
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func in(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	local := t.In(loc)
+	return &local
+}
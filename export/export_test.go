@@ -0,0 +1,85 @@
+package export_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"br.com.mlabs/export"
+	"br.com.mlabs/models"
+	"br.com.mlabs/reports"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var tx *gorm.DB
+
+var lot models.Lot
+
+func TestMain(t *testing.M) {
+	storage.ConnectTest()
+	tx = storage.StartTest()
+	lot, _ = storage.DefaultLot("Parking")
+
+	t.Run()
+
+	tx.Exec("TRUNCATE parkings CASCADE;")
+	tx.Exec("ALTER SEQUENCE parkings_id_seq RESTART WITH 1")
+	tx.Exec("ALTER SEQUENCE payments_id_seq RESTART WITH 1")
+}
+
+func TestParseOptions(t *testing.T) {
+	rng, _ := reports.ParseRange("2020-08-01", "2020-08-02")
+
+	_, err := export.ParseOptions(rng, "xml", "", false)
+	assert.Equal(t, err, utils.ErrExportNotValid)
+
+	_, err = export.ParseOptions(rng, export.CSV, "id,password", false)
+	assert.Equal(t, err, utils.ErrExportNotValid)
+
+	options, err := export.ParseOptions(rng, "", "", true)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, options.Columns, export.DefaultColumns)
+	assert.Equal(t, options.Filename(), "tickets-2020-08-01-2020-08-02.csv.gz")
+}
+
+func TestExport(t *testing.T) {
+	saoPaulo, _ := time.LoadLocation("America/Sao_Paulo")
+
+	paid := models.Parking{LotID: lot.ID, Plate: "EXP-1234", Checkin: time.Date(2020, 8, 1, 10, 0, 0, 0, saoPaulo)}
+	tx.Create(&paid)
+	tx.Create(&models.Payment{LotID: lot.ID, ParkingID: paid.ID, Paid: true, Amount: 1250, Method: models.PaymentPix})
+
+	open := models.Parking{LotID: lot.ID, Plate: "EXP-5678", Checkin: time.Date(2020, 8, 2, 23, 59, 0, 0, saoPaulo)}
+	tx.Create(&open)
+
+	outside := models.Parking{LotID: lot.ID, Plate: "EXP-9999", Checkin: time.Date(2020, 8, 3, 0, 1, 0, 0, saoPaulo)}
+	tx.Create(&outside)
+
+	rng, _ := reports.ParseRange("2020-08-01", "2020-08-02")
+
+	options, _ := export.ParseOptions(rng, export.CSV, "plate,checkin,amount,method", false)
+	var buf bytes.Buffer
+	assert.Equal(t, export.Export(&buf, lot.ID, options), nil)
+	assert.Equal(t, buf.String(), "plate,checkin,amount,method\n"+
+		"EXP-1234,2020-08-01T10:00:00-03:00,12.50,pix\n"+
+		"EXP-5678,2020-08-02T23:59:00-03:00,0.00,\n")
+
+	options, _ = export.ParseOptions(rng, export.NDJSON, "plate,paid,amount,checkout", true)
+	buf.Reset()
+	assert.Equal(t, export.Export(&buf, lot.ID, options), nil)
+
+	reader, err := gzip.NewReader(&buf)
+	assert.Equal(t, err, nil)
+	bts, _ := ioutil.ReadAll(reader)
+	lines := strings.Split(strings.TrimSpace(string(bts)), "\n")
+	assert.Equal(t, lines, []string{
+		"{\"plate\":\"EXP-1234\",\"paid\":true,\"amount\":12.50,\"checkout\":null}",
+		"{\"plate\":\"EXP-5678\",\"paid\":false,\"amount\":0.00,\"checkout\":null}",
+	})
+}
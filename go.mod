@@ -6,10 +6,14 @@ require (
 	github.com/go-playground/validator/v10 v10.4.1
 	github.com/gorilla/handlers v1.5.1
 	github.com/gorilla/mux v1.8.0
+	github.com/jackc/pgconn v1.7.0
+	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/otiai10/gosseract v2.2.1+incompatible
 	github.com/otiai10/mint v1.3.2 // indirect
 	github.com/sirupsen/logrus v1.7.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/stretchr/testify v1.5.1
+	golang.org/x/image v0.0.0-20201208152932-35266b937fa6
 	gorm.io/driver/postgres v1.0.5
 	gorm.io/gorm v1.20.5
 )
@@ -0,0 +1,40 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"net/http"
+
+	// Decoders for DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+
+	"br.com.mlabs/utils"
+	_ "golang.org/x/image/webp"
+)
+
+// Types accepted from cameras, by their sniffed content type
+var types = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// Check sniffs an upload and reads its header, it must be a JPEG, PNG or WebP of up to maxPixels,
+// so a small file that decompresses into a huge image is refused before it is decoded
+func Check(data []byte, maxPixels int) error {
+	contentType := http.DetectContentType(data)
+	if !types[contentType] {
+		return utils.ErrImageNotSupported
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return utils.ErrImageNotSupported
+	}
+	if maxPixels > 0 && int64(config.Width)*int64(config.Height) > int64(maxPixels) {
+		return utils.ErrImageTooLarge
+	}
+
+	return nil
+}
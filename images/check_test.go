@@ -0,0 +1,26 @@
+package images_test
+
+import (
+	"testing"
+
+	"br.com.mlabs/images"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	jpeg := readAsset(t, "download.jpg")
+
+	assert.Equal(t, images.Check(jpeg, 0), nil)
+	assert.Equal(t, images.Check(readAsset(t, "download2.png"), 10000000), nil)
+
+	assert.Equal(t, images.Check(jpeg, 100), utils.ErrImageTooLarge)
+	assert.Equal(t, images.Check([]byte("plain text"), 0), utils.ErrImageNotSupported)
+	assert.Equal(t, images.Check([]byte("GIF89a\x01\x00\x01\x00"), 0), utils.ErrImageNotSupported)
+	assert.Equal(t, images.Check(jpeg[:20], 0), utils.ErrImageNotSupported)
+
+	webp := readAsset(t, "sample.webp")
+	assert.Equal(t, images.Check(webp, 0), nil)
+	assert.Equal(t, images.Check(webp, 100), utils.ErrImageTooLarge)
+	assert.Equal(t, images.Check(webp[:20], 0), utils.ErrImageNotSupported)
+}
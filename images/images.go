@@ -0,0 +1,34 @@
+package images
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// Store keeps uploaded images under their key
+type Store interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// Key addresses an image by the SHA-256 of its content, with the extension of its sniffed type
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) + Extension(data)
+}
+
+// Extension is the file extension of an image's type, .bin when it is not an image
+func Extension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	}
+
+	return ".bin"
+}
@@ -0,0 +1,68 @@
+package images
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"br.com.mlabs/utils"
+)
+
+// Local keeps images in a directory of the filesystem
+type Local struct {
+	Dir string
+}
+
+// NewLocal builds a store in dir, created when missing
+func NewLocal(dir string) (Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Local{}, err
+	}
+
+	return Local{Dir: dir}, nil
+}
+
+// Put writes the image, keys are content addressed so an existing file is left alone
+func (l Local) Put(key string, data []byte) error {
+	path := filepath.Join(l.Dir, filepath.Base(key))
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	// Written aside and renamed, so a crash never leaves half an image under its key
+	tmp, err := ioutil.TempFile(l.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get reads an image
+func (l Local) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(l.Dir, filepath.Base(key)))
+	if os.IsNotExist(err) {
+		return nil, utils.ErrNotFound
+	}
+
+	return data, err
+}
+
+// Delete removes an image, a missing one is not an error
+func (l Local) Delete(key string) error {
+	err := os.Remove(filepath.Join(l.Dir, filepath.Base(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,162 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// SkewAngle finds the rotation, in degrees, that best lines up the dark pixels in rows, searching up to max either way
+func SkewAngle(g *image.Gray, max float64) float64 {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+
+	var total int64
+	for _, p := range g.Pix {
+		total += int64(p)
+	}
+	mean := uint8(total / int64(len(g.Pix)))
+
+	type point struct{ x, y float64 }
+	var dark []point
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if g.Pix[y*g.Stride+x] < mean {
+				dark = append(dark, point{float64(x), float64(y)})
+			}
+		}
+	}
+
+	// Text rows make a spiky profile of dark pixels per row, the sum of squares is highest when they are level
+	diagonal := int(math.Hypot(float64(w), float64(h))) + 1
+	profile := make([]int, 2*diagonal)
+	best, bestScore := 0.0, -1.0
+	for angle := -max; angle <= max+1e-9; angle += 0.5 {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		for i := range profile {
+			profile[i] = 0
+		}
+		for _, p := range dark {
+			profile[int(p.y*cos-p.x*sin)+diagonal]++
+		}
+
+		score := 0.0
+		for _, count := range profile {
+			score += float64(count) * float64(count)
+		}
+		if score > bestScore || (score == bestScore && math.Abs(angle) < math.Abs(best)) {
+			best, bestScore = angle, score
+		}
+	}
+
+	return best
+}
+
+// Deskew rotates the image so its text rows are level
+func Deskew(g *image.Gray, max float64) *image.Gray {
+	angle := SkewAngle(g, max)
+	if angle == 0 {
+		out := image.NewGray(g.Rect)
+		copy(out.Pix, g.Pix)
+		return out
+	}
+
+	return Rotate(g, -angle)
+}
+
+// Rotate turns the image clockwise by angle degrees around its center, keeping its size and filling corners with white
+func Rotate(g *image.Gray, angle float64) *image.Gray {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+	cx, cy := float64(w)/2, float64(h)/2
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := dx*cos + dy*sin + cx
+			sy := -dx*sin + dy*cos + cy
+			if sx < 0 || sy < 0 || sx > float64(w-1) || sy > float64(h-1) {
+				out.Pix[y*out.Stride+x] = 255
+				continue
+			}
+			out.Pix[y*out.Stride+x] = bilinear(g, sx, sy)
+		}
+	}
+
+	return out
+}
+
+// Perspective cuts the quadrilateral out of the image as a rectangle, corners go top left, top right, bottom right, bottom left
+func Perspective(g *image.Gray, corners [4]Point) *image.Gray {
+	distance := func(a Point, b Point) float64 {
+		return math.Hypot(a.X-b.X, a.Y-b.Y)
+	}
+	w := int(math.Max(distance(corners[0], corners[1]), distance(corners[3], corners[2])))
+	h := int(math.Max(distance(corners[0], corners[3]), distance(corners[1], corners[2])))
+	if w < 1 || h < 1 {
+		out := image.NewGray(g.Rect)
+		copy(out.Pix, g.Pix)
+		return out
+	}
+
+	rect := [4]Point{{0, 0}, {float64(w), 0}, {float64(w), float64(h)}, {0, float64(h)}}
+	m, ok := homography(rect, corners)
+	if !ok {
+		out := image.NewGray(g.Rect)
+		copy(out.Pix, g.Pix)
+		return out
+	}
+
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x), float64(y)
+			z := m[6]*fx + m[7]*fy + 1
+			sx := (m[0]*fx + m[1]*fy + m[2]) / z
+			sy := (m[3]*fx + m[4]*fy + m[5]) / z
+			out.Pix[y*out.Stride+x] = bilinear(g, sx, sy)
+		}
+	}
+
+	return out
+}
+
+// homography solves the 3x3 projective transform, with its last entry fixed at 1, taking each from point to its to point
+func homography(from [4]Point, to [4]Point) ([8]float64, bool) {
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y, u, v := from[i].X, from[i].Y, to[i].X, to[i].Y
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return [8]float64{}, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			factor := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+		}
+	}
+
+	var m [8]float64
+	for i := range m {
+		m[i] = a[i][8] / a[i][i]
+	}
+
+	return m, true
+}
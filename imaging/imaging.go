@@ -0,0 +1,249 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+
+	// Decoders for camera uploads
+	_ "image/jpeg"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Point is a pixel position in the source image
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Options says which steps a camera needs, the zero value leaves the image untouched
+type Options struct {
+	// Grayscale drops color, every other step implies it
+	Grayscale bool `json:"grayscale"`
+	// Perspective is the plate corners, top left, top right, bottom right and bottom left, cut out as a rectangle
+	Perspective []Point `json:"perspective,omitempty"`
+	// Deskew is the largest rotation searched, in degrees
+	Deskew float64 `json:"deskew"`
+	// Contrast stretches the histogram to the full range
+	Contrast bool `json:"contrast"`
+	// Scale enlarges the image, tesseract reads small text poorly
+	Scale float64 `json:"scale"`
+	// Threshold is the side of the window compared against each pixel, in pixels
+	Threshold int `json:"threshold"`
+	// ThresholdOffset is how much darker than its window a pixel must be to turn black
+	ThresholdOffset int `json:"threshold_offset"`
+}
+
+// Stage names passed to the debug callback, in the order they run
+const (
+	StageGray        = "gray"
+	StagePerspective = "perspective"
+	StageDeskew      = "deskew"
+	StageContrast    = "contrast"
+	StageScale       = "scale"
+	StageThreshold   = "threshold"
+)
+
+// Enabled tells if any step is set
+func (o Options) Enabled() bool {
+	return o.Grayscale || len(o.Perspective) == 4 || o.Deskew > 0 || o.Contrast || o.Scale > 1 || o.Threshold > 0
+}
+
+// Decode reads a JPEG, PNG or WebP image
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Encode writes an image as PNG
+func Encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Process runs the enabled steps, stage is called after each one and may be nil
+func Process(img image.Image, o Options, stage func(name string, img image.Image)) image.Image {
+	if !o.Enabled() {
+		return img
+	}
+
+	step := func(name string, g *image.Gray) *image.Gray {
+		if stage != nil {
+			stage(name, g)
+		}
+		return g
+	}
+
+	g := step(StageGray, Grayscale(img))
+	if len(o.Perspective) == 4 {
+		var corners [4]Point
+		copy(corners[:], o.Perspective)
+		g = step(StagePerspective, Perspective(g, corners))
+	}
+	if o.Deskew > 0 {
+		g = step(StageDeskew, Deskew(g, o.Deskew))
+	}
+	if o.Contrast {
+		g = step(StageContrast, Normalize(g))
+	}
+	if o.Scale > 1 {
+		g = step(StageScale, Upscale(g, o.Scale))
+	}
+	if o.Threshold > 0 {
+		g = step(StageThreshold, AdaptiveThreshold(g, o.Threshold, o.ThresholdOffset))
+	}
+
+	return g
+}
+
+// Grayscale converts an image to gray, with its origin moved to zero
+func Grayscale(img image.Image) *image.Gray {
+	b := img.Bounds()
+	g := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(g, g.Bounds(), img, b.Min, draw.Src)
+
+	return g
+}
+
+// Normalize stretches the levels so the darkest and brightest 1% become black and white
+func Normalize(g *image.Gray) *image.Gray {
+	var histogram [256]int
+	for _, p := range g.Pix {
+		histogram[p]++
+	}
+
+	clip := len(g.Pix) / 100
+	low, high := 0, 255
+	for seen := 0; low < 255; low++ {
+		seen += histogram[low]
+		if seen > clip {
+			break
+		}
+	}
+	for seen := 0; high > 0; high-- {
+		seen += histogram[high]
+		if seen > clip {
+			break
+		}
+	}
+
+	out := image.NewGray(g.Rect)
+	if high <= low {
+		copy(out.Pix, g.Pix)
+		return out
+	}
+
+	for i, p := range g.Pix {
+		out.Pix[i] = clamp((float64(p) - float64(low)) * 255 / float64(high-low))
+	}
+
+	return out
+}
+
+// AdaptiveThreshold turns each pixel black when it is darker than the mean of its window minus offset, white otherwise
+func AdaptiveThreshold(g *image.Gray, window int, offset int) *image.Gray {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+
+	// integral[y][x] is the sum of the pixels above and left of (x, y)
+	integral := make([]int64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row int64
+		for x := 0; x < w; x++ {
+			row += int64(g.Pix[y*g.Stride+x])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+
+	half := window / 2
+	out := image.NewGray(g.Rect)
+	for y := 0; y < h; y++ {
+		y0, y1 := maxInt(y-half, 0), minInt(y+half+1, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := maxInt(x-half, 0), minInt(x+half+1, w)
+			sum := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+			mean := sum / int64((y1-y0)*(x1-x0))
+
+			value := uint8(255)
+			if int64(g.Pix[y*g.Stride+x]) < mean-int64(offset) {
+				value = 0
+			}
+			out.Pix[y*out.Stride+x] = value
+		}
+	}
+
+	return out
+}
+
+// Upscale enlarges the image by factor with bilinear interpolation
+func Upscale(g *image.Gray, factor float64) *image.Gray {
+	w := int(float64(g.Rect.Dx()) * factor)
+	h := int(float64(g.Rect.Dy()) * factor)
+	out := image.NewGray(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Pix[y*out.Stride+x] = bilinear(g, (float64(x)+0.5)/factor-0.5, (float64(y)+0.5)/factor-0.5)
+		}
+	}
+
+	return out
+}
+
+// bilinear samples the image between pixels, outside points take the nearest edge
+func bilinear(g *image.Gray, x float64, y float64) uint8 {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+	x = clampFloat(x, 0, float64(w-1))
+	y = clampFloat(y, 0, float64(h-1))
+
+	x0, y0 := int(x), int(y)
+	x1, y1 := minInt(x0+1, w-1), minInt(y0+1, h-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	at := func(x int, y int) float64 {
+		return float64(g.Pix[y*g.Stride+x])
+	}
+	top := at(x0, y0)*(1-fx) + at(x1, y0)*fx
+	bottom := at(x0, y1)*(1-fx) + at(x1, y1)*fx
+
+	return clamp(top*(1-fy) + bottom*fy)
+}
+
+func clamp(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+func clampFloat(v float64, low float64, high float64) float64 {
+	if v < low {
+		return low
+	}
+	if v > high {
+		return high
+	}
+	return v
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,212 @@
+package imaging_test
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"testing"
+
+	"br.com.mlabs/imaging"
+	"github.com/stretchr/testify/assert"
+)
+
+// stripes draws dark horizontal bars on white, like rows of text
+func stripes(w int, h int) *image.Gray {
+	g := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			level := uint8(255)
+			if (y/6)%2 == 1 && y > h/4 && y < 3*h/4 && x > w/8 && x < 7*w/8 {
+				level = 0
+			}
+			g.SetGray(x, y, color.Gray{Y: level})
+		}
+	}
+	return g
+}
+
+func TestDecode(t *testing.T) {
+	for _, name := range []string{"download.jpg", "download.png", "sample.webp"} {
+		data, err := ioutil.ReadFile("../assets/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		img, err := imaging.Decode(data)
+		assert.Equal(t, err, nil, name)
+		assert.Equal(t, img.Bounds().Empty(), false, name)
+	}
+
+	_, err := imaging.Decode([]byte("plain text"))
+	assert.NotEqual(t, err, nil)
+}
+
+func TestGrayscale(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 10, 12, 11))
+	img.Set(10, 10, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	img.Set(11, 10, color.RGBA{A: 255})
+
+	g := imaging.Grayscale(img)
+	assert.Equal(t, g.Bounds(), image.Rect(0, 0, 2, 1))
+	assert.Equal(t, g.GrayAt(0, 0).Y, uint8(255))
+	assert.Equal(t, g.GrayAt(1, 0).Y, uint8(0))
+}
+
+func TestNormalize(t *testing.T) {
+	g := image.NewGray(image.Rect(0, 0, 100, 1))
+	for x := 0; x < 100; x++ {
+		g.SetGray(x, 0, color.Gray{Y: uint8(100 + x/2)})
+	}
+
+	out := imaging.Normalize(g)
+	assert.Equal(t, out.GrayAt(0, 0).Y, uint8(0))
+	assert.Equal(t, out.GrayAt(99, 0).Y, uint8(255))
+}
+
+func TestAdaptiveThreshold(t *testing.T) {
+	// A dark glyph on a background that fades from dark to light, a global threshold would lose one side
+	g := image.NewGray(image.Rect(0, 0, 60, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 60; x++ {
+			level := uint8(60 + 3*x)
+			if y >= 8 && y < 12 && (x%20) >= 8 && (x%20) < 12 {
+				level -= 50
+			}
+			g.SetGray(x, y, color.Gray{Y: level})
+		}
+	}
+
+	out := imaging.AdaptiveThreshold(g, 15, 10)
+	assert.Equal(t, out.GrayAt(10, 10).Y, uint8(0))
+	assert.Equal(t, out.GrayAt(50, 10).Y, uint8(0))
+	assert.Equal(t, out.GrayAt(2, 2).Y, uint8(255))
+	assert.Equal(t, out.GrayAt(55, 2).Y, uint8(255))
+}
+
+func TestUpscale(t *testing.T) {
+	g := image.NewGray(image.Rect(0, 0, 10, 4))
+	out := imaging.Upscale(g, 2.5)
+	assert.Equal(t, out.Bounds(), image.Rect(0, 0, 25, 10))
+}
+
+func TestDeskew(t *testing.T) {
+	g := stripes(200, 120)
+	assert.Equal(t, imaging.SkewAngle(g, 10), 0.0)
+
+	tilted := imaging.Rotate(g, 5)
+	assert.InDelta(t, imaging.SkewAngle(tilted, 10), 5, 0.5)
+
+	level := imaging.Deskew(tilted, 10)
+	assert.InDelta(t, imaging.SkewAngle(level, 10), 0, 0.5)
+}
+
+func TestPerspective(t *testing.T) {
+	g := stripes(200, 120)
+
+	out := imaging.Perspective(g, [4]imaging.Point{{X: 25, Y: 30}, {X: 175, Y: 30}, {X: 175, Y: 90}, {X: 25, Y: 90}})
+	assert.Equal(t, out.Bounds(), image.Rect(0, 0, 150, 60))
+	for _, p := range []image.Point{{0, 0}, {40, 3}, {40, 11}, {100, 40}} {
+		assert.Equal(t, out.GrayAt(p.X, p.Y), g.GrayAt(p.X+25, p.Y+30))
+	}
+
+	// A trapezoid seen from below comes out as a rectangle
+	out = imaging.Perspective(g, [4]imaging.Point{{X: 40, Y: 30}, {X: 160, Y: 30}, {X: 190, Y: 90}, {X: 10, Y: 90}})
+	assert.Equal(t, out.Bounds(), image.Rect(0, 0, 180, 67))
+}
+
+func TestProcess(t *testing.T) {
+	data, err := ioutil.ReadFile("../assets/download.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := imaging.Decode(data)
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, imaging.Process(img, imaging.Options{}, nil), img)
+
+	var stages []string
+	out := imaging.Process(img, imaging.Options{Deskew: 5, Contrast: true, Scale: 2, Threshold: 31, ThresholdOffset: 10}, func(name string, _ image.Image) {
+		stages = append(stages, name)
+	})
+	assert.Equal(t, stages, []string{imaging.StageGray, imaging.StageDeskew, imaging.StageContrast, imaging.StageScale, imaging.StageThreshold})
+	assert.Equal(t, out.Bounds().Dx(), 2*img.Bounds().Dx())
+
+	for _, p := range out.(*image.Gray).Pix {
+		if p != 0 && p != 255 {
+			t.Fatalf("threshold left level %d", p)
+		}
+	}
+
+	_, err = imaging.Encode(out)
+	assert.Equal(t, err, nil)
+}
+
+func TestLocate(t *testing.T) {
+	// A plate of eight characters and a square sign of text elsewhere in the scene
+	g := image.NewGray(image.Rect(0, 0, 400, 300))
+	for i := range g.Pix {
+		g.Pix[i] = 180
+	}
+	fill := func(r image.Rectangle, level uint8) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				g.SetGray(x, y, color.Gray{Y: level})
+			}
+		}
+	}
+	fill(image.Rect(100, 200, 300, 260), 250)
+	for i := 0; i < 8; i++ {
+		fill(image.Rect(110+i*23, 210, 110+i*23+12, 250), 20)
+	}
+	for row := 0; row < 5; row++ {
+		for i := 0; i < 5; i++ {
+			fill(image.Rect(20+i*12, 20+row*14, 20+i*12+6, 20+row*14+8), 20)
+		}
+	}
+
+	candidates := imaging.Locate(g, 5)
+	assert.Equal(t, len(candidates) > 0, true)
+	assert.Equal(t, candidates[0].Box.In(image.Rect(90, 195, 310, 265)), true)
+	assert.Equal(t, candidates[0].Box.Dx() > 180, true)
+
+	// Lines of the sign look like plates too, but their edges are sparser
+	for _, c := range candidates[1:] {
+		assert.Equal(t, c.Box.In(image.Rect(10, 15, 85, 95)), true)
+		assert.Equal(t, c.Score < candidates[0].Score, true)
+	}
+
+	assert.Equal(t, len(imaging.Locate(g, 0)), 0)
+}
+
+func TestLocateAssets(t *testing.T) {
+	plates := map[string]image.Rectangle{
+		"download.jpg":  image.Rect(150, 330, 1075, 500),
+		"download2.jpg": image.Rect(20, 20, 180, 60),
+	}
+
+	for name, plate := range plates {
+		data, err := ioutil.ReadFile("../assets/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, _ := imaging.Decode(data)
+
+		candidates := imaging.Locate(imaging.Grayscale(img), 3)
+		assert.Equal(t, len(candidates) > 0, true)
+
+		box := candidates[0].Box
+		overlap := box.Intersect(plate)
+		assert.Equal(t, overlap.Dx()*overlap.Dy()*10 > plate.Dx()*plate.Dy()*8, true, name)
+	}
+}
+
+func TestPadCrop(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 50)
+	assert.Equal(t, imaging.Pad(image.Rect(10, 10, 60, 30), 0.25, bounds), image.Rect(5, 5, 65, 35))
+	assert.Equal(t, imaging.Pad(image.Rect(0, 20, 100, 50), 0.5, bounds), image.Rect(0, 5, 100, 50))
+
+	g := stripes(200, 120)
+	crop := imaging.Crop(g, image.Rect(50, 60, 80, 70))
+	assert.Equal(t, crop.Bounds(), image.Rect(0, 0, 30, 10))
+	assert.Equal(t, color.GrayModel.Convert(crop.At(3, 4)), g.At(53, 64))
+}
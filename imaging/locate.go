@@ -0,0 +1,244 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Plate shapes accepted by Locate, as width over height, Brazilian plates are about 3 to 1
+const (
+	MinPlateAspect = 1.8
+	MaxPlateAspect = 6.5
+)
+
+// Candidate is a region that may hold a plate, scored by how dense its vertical edges are
+type Candidate struct {
+	Box   image.Rectangle
+	Score float64
+}
+
+// Locate finds up to max regions shaped like a plate, best first. Characters are dense with vertical edges,
+// so edges are joined sideways into blobs and the blobs with a plate's aspect ratio are kept.
+func Locate(g *image.Gray, max int) []Candidate {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+	if w < 3 || h < 3 {
+		return nil
+	}
+
+	edges := verticalEdges(g)
+
+	// Join the strokes of neighbouring characters, and the rows of a single character
+	joined := dilate(edges, w, h, maxInt(w/60, 2), maxInt(h/120, 1))
+
+	var candidates []Candidate
+	for _, box := range mergeRows(components(joined, w, h)) {
+		bw, bh := box.Dx(), box.Dy()
+		aspect := float64(bw) / float64(bh)
+		if bh < 8 || bw < 24 || aspect < MinPlateAspect || aspect > MaxPlateAspect {
+			continue
+		}
+
+		count := 0
+		for y := box.Min.Y; y < box.Max.Y; y++ {
+			for x := box.Min.X; x < box.Max.X; x++ {
+				if edges[y*w+x] {
+					count++
+				}
+			}
+		}
+		density := float64(count) / float64(bw*bh)
+
+		// Larger regions read better, the square root keeps size from outweighing density
+		candidates = append(candidates, Candidate{
+			Box:   box,
+			Score: density * math.Sqrt(float64(bw*bh)),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i int, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	if len(candidates) > max {
+		candidates = candidates[:max]
+	}
+
+	return candidates
+}
+
+// Pad grows a box by a fraction of its height on every side, kept inside bounds, so the crop does not cut characters
+func Pad(box image.Rectangle, fraction float64, bounds image.Rectangle) image.Rectangle {
+	margin := int(float64(box.Dy()) * fraction)
+	return image.Rect(box.Min.X-margin, box.Min.Y-margin, box.Max.X+margin, box.Max.Y+margin).Intersect(bounds)
+}
+
+// Crop copies a region of an image
+func Crop(img image.Image, box image.Rectangle) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, box.Dx(), box.Dy()))
+	for y := 0; y < box.Dy(); y++ {
+		for x := 0; x < box.Dx(); x++ {
+			out.Set(x, y, img.At(b.Min.X+box.Min.X+x, b.Min.Y+box.Min.Y+y))
+		}
+	}
+
+	return out
+}
+
+// verticalEdges marks pixels whose horizontal gradient is well above the image's average
+func verticalEdges(g *image.Gray) []bool {
+	w, h := g.Rect.Dx(), g.Rect.Dy()
+	gradient := make([]int, w*h)
+
+	var total int64
+	for y := 0; y < h; y++ {
+		for x := 1; x < w-1; x++ {
+			d := int(g.Pix[y*g.Stride+x+1]) - int(g.Pix[y*g.Stride+x-1])
+			if d < 0 {
+				d = -d
+			}
+			gradient[y*w+x] = d
+			total += int64(d)
+		}
+	}
+
+	threshold := int(3 * total / int64(w*h))
+	if threshold < 24 {
+		threshold = 24
+	}
+
+	edges := make([]bool, w*h)
+	for i, d := range gradient {
+		edges[i] = d >= threshold
+	}
+
+	return edges
+}
+
+// dilate grows every set pixel by dx sideways and dy up and down
+func dilate(in []bool, w int, h int, dx int, dy int) []bool {
+	rows := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		last := -w
+		for x := 0; x < w; x++ {
+			if in[y*w+x] {
+				last = x
+			}
+			rows[y*w+x] = x-last <= dx
+		}
+		last = 2 * w
+		for x := w - 1; x >= 0; x-- {
+			if in[y*w+x] {
+				last = x
+			}
+			if last-x <= dx {
+				rows[y*w+x] = true
+			}
+		}
+	}
+
+	out := make([]bool, w*h)
+	for x := 0; x < w; x++ {
+		last := -h
+		for y := 0; y < h; y++ {
+			if rows[y*w+x] {
+				last = y
+			}
+			out[y*w+x] = y-last <= dy
+		}
+		last = 2 * h
+		for y := h - 1; y >= 0; y-- {
+			if rows[y*w+x] {
+				last = y
+			}
+			if last-y <= dy {
+				out[y*w+x] = true
+			}
+		}
+	}
+
+	return out
+}
+
+// mergeRows joins blobs side by side on the same line, like the letter and digit groups of a plate
+func mergeRows(boxes []image.Rectangle) []image.Rectangle {
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(boxes) && !merged; i++ {
+			for j := i + 1; j < len(boxes); j++ {
+				if !sameRow(boxes[i], boxes[j]) {
+					continue
+				}
+				boxes[i] = boxes[i].Union(boxes[j])
+				boxes = append(boxes[:j], boxes[j+1:]...)
+				merged = true
+				break
+			}
+		}
+	}
+
+	return boxes
+}
+
+// sameRow tells if two blobs have about the same height, overlap vertically and are close sideways
+func sameRow(a image.Rectangle, b image.Rectangle) bool {
+	low, high := minInt(a.Dy(), b.Dy()), maxInt(a.Dy(), b.Dy())
+	if low*3 < high*2 {
+		return false
+	}
+
+	overlap := minInt(a.Max.Y, b.Max.Y) - maxInt(a.Min.Y, b.Min.Y)
+	if overlap*10 < low*6 {
+		return false
+	}
+
+	gap := maxInt(a.Min.X, b.Min.X) - minInt(a.Max.X, b.Max.X)
+	return gap*2 <= high
+}
+
+// components returns the bounding box of each 4-connected blob
+func components(in []bool, w int, h int) []image.Rectangle {
+	seen := make([]bool, w*h)
+	var boxes []image.Rectangle
+	var stack []int
+
+	for start := range in {
+		if !in[start] || seen[start] {
+			continue
+		}
+
+		box := image.Rect(start%w, start/w, start%w+1, start/w+1)
+		seen[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%w, i/w
+			box = box.Union(image.Rect(x, y, x+1, y+1))
+
+			neighbours := [4]int{-1, -1, -1, -1}
+			if x > 0 {
+				neighbours[0] = i - 1
+			}
+			if x < w-1 {
+				neighbours[1] = i + 1
+			}
+			if y > 0 {
+				neighbours[2] = i - w
+			}
+			if y < h-1 {
+				neighbours[3] = i + w
+			}
+			for _, n := range neighbours {
+				if n >= 0 && in[n] && !seen[n] {
+					seen[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+
+		boxes = append(boxes, box)
+	}
+
+	return boxes
+}
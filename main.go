@@ -1,10 +1,23 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
+	"strings"
+	_ "time/tzdata"
 
 	"br.com.mlabs/api"
+	"br.com.mlabs/config"
+	"br.com.mlabs/export"
+	"br.com.mlabs/images"
+	"br.com.mlabs/models"
+	"br.com.mlabs/plates"
+	"br.com.mlabs/recognition"
+	"br.com.mlabs/recognition/tesseract"
+	"br.com.mlabs/reports"
 	"br.com.mlabs/storage"
+	"br.com.mlabs/usecases"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,6 +28,173 @@ func main() {
 		TimestampFormat: "02/01/2006.15:04:05",
 	})
 	logrus.SetOutput(os.Stdout)
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	plates.Enable(config.PlateFormats())
+
+	recognizer, err := newRecognizer()
+	if err != nil {
+		logrus.Panic(err.Error())
+	}
+	usecases.SetRecognizer(recognizer)
+
+	store, err := newImageStore()
+	if err != nil {
+		logrus.Panic(err.Error())
+	}
+	usecases.SetImageStore(store)
+
 	storage.Connect()
+	go usecases.StartBookingExpiry()
+	go usecases.StartIdempotencyPurge()
+	go usecases.StartImagePurge()
+	usecases.StartStreams()
 	api.Start()
 }
+
+// commands run instead of the server when named as the first argument
+var commands = map[string]func(args []string) error{
+	"export": runExport,
+	"lot":    runLot,
+	"user":   runUser,
+}
+
+// newRecognizer builds the plate recognizer chosen with OCR_ENGINE, the fake engine reads its plates from OCR_FAKE_PLATES
+func newRecognizer() (recognition.PlateRecognizer, error) {
+	switch config.OCREngine() {
+	case recognition.EngineTesseract:
+		return tesseract.Recognizer{}, nil
+	case recognition.EngineFake:
+		return recognition.LoadFake(config.OCRFakePlates())
+	}
+
+	return nil, fmt.Errorf("unknown OCR engine %q", config.OCREngine())
+}
+
+// newImageStore builds the image store chosen with IMAGE_STORE
+func newImageStore() (images.Store, error) {
+	switch config.ImageStore() {
+	case "local":
+		return images.NewLocal(config.ImageDir())
+	case "s3":
+		s3 := config.S3()
+		if s3.Bucket == "" {
+			return nil, fmt.Errorf("S3_BUCKET not found")
+		}
+		return s3, nil
+	}
+
+	return nil, fmt.Errorf("unknown image store %q", config.ImageStore())
+}
+
+// runExport writes the same export as GET /export/tickets to a file or stdout
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	from := flags.String("from", "", "first day, YYYY-MM-DD")
+	to := flags.String("to", "", "last day, YYYY-MM-DD")
+	format := flags.String("format", export.CSV, "csv or ndjson")
+	columns := flags.String("columns", "", "comma separated columns, all of them by default")
+	gz := flags.Bool("gzip", false, "gzip the output")
+	out := flags.String("out", "", "output file, stdout by default")
+	lotName := flags.String("lot", "", "lot name, LOT_NAME by default")
+	flags.Parse(args)
+
+	// Stdout may be the export itself
+	logrus.SetOutput(os.Stderr)
+
+	rng, err := reports.ParseRange(*from, *to)
+	if err != nil {
+		return err
+	}
+
+	options, err := export.ParseOptions(rng, *format, *columns, *gz)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+
+	storage.Connect()
+	lot, err := findLot(*lotName)
+	if err != nil {
+		return err
+	}
+
+	return export.Export(w, lot.ID, options)
+}
+
+// runLot creates a lot, in a company when one is named
+func runLot(args []string) error {
+	flags := flag.NewFlagSet("lot", flag.ExitOnError)
+	name := flags.String("name", "", "lot name")
+	company := flags.String("company", "", "company name, created the first time")
+	capacity := flags.Int("capacity", -1, "number of spaces, zero is unlimited, PARKING_CAPACITY by default")
+	formats := flags.String("plate-formats", "", "plate format ids separated by commas, PLATE_FORMATS by default")
+	flags.Parse(args)
+
+	request := models.LotRequest{Name: *name}
+	if *formats != "" {
+		request.PlateFormats = strings.Split(*formats, ",")
+	}
+	if *capacity >= 0 {
+		request.Capacity = capacity
+	}
+
+	storage.Connect()
+	id, err := usecases.CreateLot(*company, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(id)
+	return nil
+}
+
+// runUser creates a user of a lot and prints its API key, which is not shown again
+func runUser(args []string) error {
+	flags := flag.NewFlagSet("user", flag.ExitOnError)
+	lotName := flags.String("lot", "", "lot name, LOT_NAME by default")
+	name := flags.String("name", "", "user name")
+	role := flags.String("role", models.RoleOperator, "operator, admin or superadmin")
+	flags.Parse(args)
+
+	storage.Connect()
+	lot, err := findLot(*lotName)
+	if err != nil {
+		return err
+	}
+
+	key, err := usecases.CreateUser(lot.Name, models.UserRequest{Name: *name, Role: *role})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(key.Key)
+	return nil
+}
+
+// findLot is the lot with the name, the default lot when it is empty
+func findLot(name string) (models.Lot, error) {
+	if name == "" {
+		caller, err := usecases.DefaultCaller()
+		return caller.Lot, err
+	}
+
+	return storage.LotByName(name)
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Booking statuses
+const (
+	BookingBooked    = "booked"
+	BookingCancelled = "cancelled"
+	BookingExpired   = "expired"
+	BookingConverted = "converted"
+)
+
+// Booking is an advance reservation of a space for a time window
+type Booking struct {
+	gorm.Model
+
+	LotID uint `gorm:"index"`
+
+	Plate    string    `gorm:"not null;index"`
+	Category string    `gorm:"not null;default:car"`
+	StartsAt time.Time `gorm:"not null"`
+	EndsAt   time.Time `gorm:"not null"`
+	Prepaid  int64
+	Status   string `gorm:"not null;default:booked;index"`
+
+	ParkingID *uint
+}
+
+// BookingRequest will hold an advance reservation
+type BookingRequest struct {
+	Plate    string    `json:"plate" validate:"plate"`
+	Category string    `json:"category,omitempty" validate:"omitempty,oneof=motorcycle car van truck"`
+	StartsAt time.Time `json:"starts_at" validate:"required"`
+	EndsAt   time.Time `json:"ends_at" validate:"required,gtfield=StartsAt"`
+	Prepaid  int64     `json:"prepaid" validate:"gte=0"`
+}
+
+// BookingEntry is a booking as shown to the customer
+type BookingEntry struct {
+	ID        uint      `json:"id"`
+	Plate     string    `json:"plate"`
+	Category  string    `json:"category"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Prepaid   int64     `json:"prepaid"`
+	Status    string    `json:"status"`
+	ParkingID *uint     `json:"parking_id,omitempty"`
+}
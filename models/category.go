@@ -0,0 +1,48 @@
+package models
+
+import "br.com.mlabs/plates"
+
+// Vehicle categories, each with its own spaces and tariff
+const (
+	CategoryMotorcycle = "motorcycle"
+	CategoryCar        = "car"
+	CategoryVan        = "van"
+	CategoryTruck      = "truck"
+)
+
+// Categories is every vehicle category, in the order reports list them
+var Categories = []string{CategoryMotorcycle, CategoryCar, CategoryVan, CategoryTruck}
+
+// Capacity is the number of spaces of the lot and of each category, zero means unlimited
+type Capacity struct {
+	Total      int
+	Categories map[string]int
+}
+
+// Of is the number of spaces of a category, zero means unlimited
+func (c Capacity) Of(category string) int {
+	return c.Categories[category]
+}
+
+// Limited tells if any count of spaces must be checked
+func (c Capacity) Limited() bool {
+	if c.Total > 0 {
+		return true
+	}
+	for _, spaces := range c.Categories {
+		if spaces > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PlateCategory is the category a plate's format is only issued to, like Mercosul motorcycle plates, cars otherwise
+func PlateCategory(plate string) string {
+	if format, ok := plates.Lookup(plate); ok && format.Vehicle != "" {
+		return format.Vehicle
+	}
+
+	return CategoryCar
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Gate event types
+const (
+	EventCheckin  = "checkin"
+	EventPayment  = "payment"
+	EventCheckout = "checkout"
+)
+
+// Import result statuses
+const (
+	ImportApplied   = "applied"
+	ImportDuplicate = "duplicate"
+	ImportFailed    = "failed"
+)
+
+// GateEvent records an imported gate event so it is applied only once, event ids are generated by each device
+type GateEvent struct {
+	gorm.Model
+
+	LotID     uint   `gorm:"uniqueIndex:idx_lot_device_event"`
+	DeviceID  string `gorm:"not null;uniqueIndex:idx_lot_device_event"`
+	EventID   string `gorm:"not null;uniqueIndex:idx_lot_device_event"`
+	Type      string `gorm:"not null"`
+	Time      time.Time
+	ParkingID uint
+}
+
+// ImportRequest is a batch of events a gate recorded while offline
+type ImportRequest struct {
+	DeviceID string        `json:"device_id" validate:"required"`
+	Events   []ImportEvent `json:"events" validate:"min=1,max=1000"`
+}
+
+// ImportEvent is a check-in, payment or checkout with the time it happened, payments and checkouts
+// point to their ticket by id or token, or by the event id of the check-in sent by the same device
+type ImportEvent struct {
+	EventID       string    `json:"event_id" validate:"required,max=64"`
+	Type          string    `json:"type" validate:"oneof=checkin payment checkout"`
+	Time          time.Time `json:"time" validate:"required"`
+	Plate         string    `json:"plate"`
+	Ticket        string    `json:"ticket"`
+	TicketEventID string    `json:"ticket_event_id"`
+	Method        string    `json:"method" validate:"omitempty,oneof=cash credit debit pix"`
+
+	ParkingID uint `json:"-"`
+}
+
+// ImportResult tells what happened to each imported event
+type ImportResult struct {
+	EventID  string `json:"event_id"`
+	Status   string `json:"status"`
+	TicketID uint   `json:"ticket_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
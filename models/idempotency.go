@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// IdempotencyKey holds the response given to a request sent with an Idempotency-Key header,
+// a zero status means the first request is still running until the key expires
+type IdempotencyKey struct {
+	Key         string `gorm:"primaryKey"`
+	Fingerprint string `gorm:"not null"`
+	Status      int    `gorm:"not null;default:0"`
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
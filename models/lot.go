@@ -0,0 +1,149 @@
+package models
+
+import (
+	"strings"
+
+	"br.com.mlabs/plates"
+	"gorm.io/gorm"
+)
+
+// User roles, operators work in their lot and admins also see the other lots of its company.
+// Super-admins see every lot, they are only created from the command line
+const (
+	RoleOperator   = "operator"
+	RoleAdmin      = "admin"
+	RoleSuperAdmin = "superadmin"
+)
+
+// Company operates one or more lots
+type Company struct {
+	gorm.Model
+
+	Name string `gorm:"not null;uniqueIndex"`
+}
+
+// Lot is a garage, its tickets, payments, bookings, reviews, gate events and users are only seen from it
+type Lot struct {
+	gorm.Model
+
+	Name      string `gorm:"not null;uniqueIndex"`
+	CompanyID *uint
+	Company   *Company
+
+	// Capacity is the number of spaces, zero means unlimited and nil keeps PARKING_CAPACITY
+	Capacity *int
+	// Categories replaces the spaces and tariff of the vehicle categories it lists
+	Categories []LotCategory
+	// PlateFormats is the ids of the plate formats it accepts separated by commas, empty keeps PLATE_FORMATS
+	PlateFormats string
+}
+
+// Formats is the plate formats the lot accepts, in the order ties are broken
+func (l Lot) Formats() []plates.Format {
+	if formats := plates.Formats(strings.Split(l.PlateFormats, ",")); formats != nil {
+		return formats
+	}
+	return plates.Enabled()
+}
+
+// NormalizePlate is NormalizePlate in the formats of the lot
+func (l Lot) NormalizePlate(plate string) string {
+	normalized, _, _ := plates.NormalizeIn(l.Formats(), plate)
+	return normalized
+}
+
+// Check is Check with plates in the formats of the lot
+func (l Lot) Check(model interface{}) []FieldError {
+	return check(l.Formats(), model)
+}
+
+// Validate is Validate with plates in the formats of the lot
+func (l Lot) Validate(model interface{}) bool {
+	return len(l.Check(model)) == 0
+}
+
+// Verify is Verify with plates in the formats of the lot
+func (l Lot) Verify(model interface{}, err error) error {
+	if fields := l.Check(model); fields != nil {
+		return Invalid{Err: err, Fields: fields}
+	}
+	return nil
+}
+
+// LotCategory is the number of spaces, zero meaning unlimited, and the tariff of a vehicle category in a lot
+type LotCategory struct {
+	gorm.Model
+
+	LotID    uint   `gorm:"not null;uniqueIndex:idx_lot_category"`
+	Category string `gorm:"not null;uniqueIndex:idx_lot_category"`
+	Spaces   int
+	Tariff   Tariff `gorm:"embedded;embeddedPrefix:tariff_"`
+}
+
+// User calls the API with a key, only its hash is stored
+type User struct {
+	gorm.Model
+
+	Name    string `gorm:"not null"`
+	LotID   uint   `gorm:"not null;index"`
+	Lot     Lot
+	Role    string `gorm:"not null;default:operator"`
+	KeyHash string `gorm:"not null;uniqueIndex"`
+}
+
+// Caller is who a request is made for, every read and write is scoped to its lot
+type Caller struct {
+	Lot  Lot
+	Role string
+}
+
+// Admin tells if the caller may see the other lots of its company
+func (c Caller) Admin() bool {
+	return c.Role == RoleAdmin || c.Role == RoleSuperAdmin
+}
+
+// SuperAdmin tells if the caller may see every lot
+func (c Caller) SuperAdmin() bool {
+	return c.Role == RoleSuperAdmin
+}
+
+// LotRequest creates a lot in the company of the admin
+type LotRequest struct {
+	Name       string               `json:"name" validate:"required,max=100"`
+	Capacity   *int                 `json:"capacity" validate:"omitempty,gte=0"`
+	Categories []LotCategoryRequest `json:"categories" validate:"dive"`
+	// PlateFormats is the ids of the plate formats it accepts, PLATE_FORMATS when empty
+	PlateFormats []string `json:"plate_formats" validate:"dive,plateformat"`
+}
+
+// LotCategoryRequest sets the spaces and tariff of a vehicle category in a new lot, prices in cents
+type LotCategoryRequest struct {
+	Category       string `json:"category" validate:"oneof=motorcycle car van truck"`
+	Spaces         int    `json:"spaces" validate:"gte=0"`
+	GraceMinutes   int    `json:"grace_minutes" validate:"gte=0"`
+	FirstHour      int64  `json:"first_hour" validate:"gte=0"`
+	AdditionalHour int64  `json:"additional_hour" validate:"gte=0"`
+	DailyMax       int64  `json:"daily_max" validate:"gte=0"`
+}
+
+// LotEntry is a lot as listed to admins
+type LotEntry struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Company  string `json:"company,omitempty"`
+	Capacity *int   `json:"capacity,omitempty"`
+	// PlateFormats is empty when the lot keeps PLATE_FORMATS
+	PlateFormats []string `json:"plate_formats,omitempty"`
+}
+
+// UserRequest creates a user of a lot
+type UserRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	Role string `json:"role" validate:"oneof=operator admin"`
+}
+
+// UserKey is a new user with its key, the key is shown only once
+type UserKey struct {
+	ID  uint   `json:"id"`
+	Key string `json:"key"`
+}
@@ -1,11 +1,8 @@
 package models
 
 import (
-	"regexp"
 	"time"
 
-	"github.com/go-playground/validator/v10"
-	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
@@ -13,22 +10,51 @@ import (
 type Parking struct {
 	gorm.Model
 
+	LotID uint `gorm:"index;index:idx_lot_checkin"`
+
 	Plate    string    `gorm:"not null;varchar(8)"`
-	Checkin  time.Time `sql:"DEFAULT:current_timestamp"`
+	Checkin  time.Time `gorm:"index:idx_lot_checkin" sql:"DEFAULT:current_timestamp"`
 	Checkout *time.Time
+
+	// Category is the vehicle category, it picks the spaces and the tariff
+	Category string `gorm:"not null;default:car;index"`
+
+	BookingID *uint
+	Prepaid   int64
+
+	// PlateFormat and Country are the registered format the plate was written in
+	PlateFormat string
+	Country     string
+
+	// Image is the key of the uploaded image in the image store, emptied when it is purged
+	Image string
 }
 
 // ParkingRequest will hold the parking reservation
 type ParkingRequest struct {
 	Plate string `json:"plate" validate:"plate"`
+	// Category is set by the operator, when empty it comes from the plate format
+	Category string `json:"category,omitempty" validate:"omitempty,oneof=motorcycle car van truck"`
+	Image    string `json:"-"`
+}
+
+// ExitResult tells the exit gate to open, or shows the amount due when the ticket must be paid first
+type ExitResult struct {
+	ID          uint         `json:"id"`
+	Plate       string       `json:"plate"`
+	Open        bool         `json:"open"`
+	AmountDue   int64        `json:"amount_due"`
+	Recognition *Recognition `json:"recognition,omitempty"`
 }
 
 // ParkingHistoryEntry is a parking history entry
 type ParkingHistoryEntry struct {
-	ID   uint   `json:"id"`
-	Time string `json:"time"`
-	Paid bool   `json:"paid"`
-	Left bool   `json:"left"`
+	ID       uint   `json:"id"`
+	Lot      string `json:"lot,omitempty"`
+	Category string `json:"category"`
+	Time     string `json:"time"`
+	Paid     bool   `json:"paid"`
+	Left     bool   `json:"left"`
 }
 
 // ParkingPayments is used to begin history assemble
@@ -38,29 +64,9 @@ type ParkingPayments struct {
 	Checkin  time.Time
 	Checkout *time.Time
 	Plate    string
+	Category string
+	LotID    uint
 }
 
 // ParkingHistory holds all entries for a plate
 type ParkingHistory []ParkingHistoryEntry
-
-// Validate returns true if the request is valid
-func Validate(model interface{}) bool {
-	v := validator.New()
-	v.RegisterValidation("plate", plateValidation, false)
-	err := v.Struct(model)
-	if err != nil {
-		valError := err.(validator.ValidationErrors)
-		logrus.Warnf("Validation failed for: %v %s", model, valError.Error())
-		return false
-	}
-
-	return true
-}
-
-func plateValidation(fl validator.FieldLevel) bool {
-	str := fl.Field().String()
-	old, _ := regexp.MatchString(`^[A-Z]{3}-[0-9]{4}\z`, str)
-	mercosul, _ := regexp.MatchString(`^[A-Z]{3}[0-9][A-Z][0-9]{2}\z`, str)
-
-	return old || mercosul
-}
@@ -20,3 +20,32 @@ func TestValidate(t *testing.T) {
 	request.Plate = "ABC-1234"
 	assert.Equal(t, models.Validate(request), true)
 }
+
+func TestCheck(t *testing.T) {
+	assert.Equal(t, len(models.Check(models.ParkingRequest{Plate: "ABC-1234"})), 0)
+
+	errors := models.Check(models.ParkingRequest{Plate: "ABC-123"})
+	assert.Equal(t, errors, []models.FieldError{{
+		Field:   "plate",
+		Rule:    "plate",
+		Value:   "ABC-123",
+		Message: "must be a plate, formats: ABC-1234, ABC1D23",
+	}})
+
+	// Fields are named as in JSON, every failing field is listed
+	errors = models.Check(models.ImportRequest{Events: []models.ImportEvent{{Type: "parked"}}})
+	assert.Equal(t, len(errors), 1)
+	assert.Equal(t, errors[0].Field, "device_id")
+	assert.Equal(t, errors[0].Rule, "required")
+	assert.Equal(t, errors[0].Message, "is required")
+
+	errors = models.Check(models.ImportEvent{Type: "parked", Method: "check"})
+	assert.Equal(t, len(errors), 4)
+	assert.Equal(t, errors[1], models.FieldError{
+		Field:   "type",
+		Rule:    "oneof",
+		Value:   "parked",
+		Message: "must be one of: checkin, payment, checkout",
+	})
+	assert.Equal(t, errors[3].Field, "method")
+}
@@ -2,12 +2,45 @@ package models
 
 import "gorm.io/gorm"
 
+// Payment methods
+const (
+	PaymentCash   = "cash"
+	PaymentCredit = "credit"
+	PaymentDebit  = "debit"
+	PaymentPix    = "pix"
+)
+
 // Payment will hold all the payment information
 type Payment struct {
 	gorm.Model
 
-	ParkingID uint
+	LotID     uint `gorm:"index"`
+	ParkingID uint `gorm:"uniqueIndex"`
 	Parking   Parking
 
-	Paid bool
+	Paid   bool
+	Amount int64
+	Method string
+	Lines  string
+}
+
+// PaymentRequest will hold how a parking space is being paid
+type PaymentRequest struct {
+	Method string `json:"method" validate:"omitempty,oneof=cash credit debit pix"`
+}
+
+// Receipt numbers payments from a gap-free sequence of its lot
+type Receipt struct {
+	gorm.Model
+
+	LotID     uint `gorm:"uniqueIndex:idx_lot_receipt"`
+	Number    uint `gorm:"not null;uniqueIndex:idx_lot_receipt"`
+	PaymentID uint `gorm:"not null;uniqueIndex"`
+	Payment   Payment
+}
+
+// ReceiptSequence holds the last receipt number issued in a lot, its id is the lot's
+type ReceiptSequence struct {
+	ID   uint
+	Last uint `gorm:"not null"`
 }
@@ -0,0 +1,46 @@
+package models
+
+import "br.com.mlabs/plates"
+
+// NormalizePlate turns a plate as typed or read into the canonical form of the enabled format it fits, like AAA-1234 or AAA1A23.
+// Case, spaces, dashes and dots are ignored, input that fits no format is only trimmed and upper-cased, so it fails validation
+func NormalizePlate(plate string) string {
+	normalized, _, _ := plates.Normalize(plate)
+	return normalized
+}
+
+// MercosulPlate is the Mercosul plate an old Brazilian plate was converted to, the second digit becomes a letter, 0 is A up to 9 is J.
+// It is empty when the plate is not an old Brazilian one
+func MercosulPlate(plate string) string {
+	old, _ := plates.Get(plates.BROld)
+	chars := []rune(NormalizePlate(plate))
+	if !old.Match(string(chars)) {
+		return ""
+	}
+
+	return string(chars[:3]) + string(chars[4]) + string('A'+chars[5]-'0') + string(chars[6:])
+}
+
+// OldPlate is the old plate a Brazilian Mercosul plate was converted from, empty when the plate is not one that could have been converted
+func OldPlate(plate string) string {
+	mercosul, _ := plates.Get(plates.BRMercosul)
+	chars := []rune(NormalizePlate(plate))
+	if !mercosul.Match(string(chars)) || chars[4] > 'J' {
+		return ""
+	}
+
+	return string(chars[:3]) + "-" + string(chars[3]) + string('0'+chars[4]-'A') + string(chars[5:])
+}
+
+// PlateForms is the canonical plate and the form of the same car before or after it was converted, so history finds both
+func PlateForms(plate string) []string {
+	plate = NormalizePlate(plate)
+	if other := MercosulPlate(plate); other != "" {
+		return []string{plate, other}
+	}
+	if other := OldPlate(plate); other != "" {
+		return []string{plate, other}
+	}
+
+	return []string{plate}
+}
@@ -0,0 +1,75 @@
+package models_test
+
+import (
+	"testing"
+
+	"br.com.mlabs/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePlate(t *testing.T) {
+	cases := map[string]string{
+		"ABC-1234":    "ABC-1234",
+		"abc 1234":    "ABC-1234",
+		"ABC1234":     "ABC-1234",
+		" abc-1234\n": "ABC-1234",
+		"ABC.1234":    "ABC-1234",
+		"ABC1C34":     "ABC1C34",
+		"abc 1c34":    "ABC1C34",
+		"ABC-1C34":    "ABC1C34",
+		"ABC-123":     "ABC-123",
+		" notvalid ":  "NOTVALID",
+		"":            "",
+	}
+
+	for input, expected := range cases {
+		assert.Equal(t, models.NormalizePlate(input), expected, input)
+	}
+
+	// Whatever was typed, the canonical form validates
+	assert.Equal(t, models.Validate(models.ParkingRequest{Plate: models.NormalizePlate("abc 1234")}), true)
+	assert.Equal(t, models.Validate(models.ParkingRequest{Plate: models.NormalizePlate("abc1c34")}), true)
+	assert.Equal(t, models.Validate(models.ParkingRequest{Plate: models.NormalizePlate("abc 123")}), false)
+}
+
+func TestPlateForms(t *testing.T) {
+	assert.Equal(t, models.MercosulPlate("ABC-1234"), "ABC1C34")
+	assert.Equal(t, models.MercosulPlate("XYZ-9099"), "XYZ9A99")
+	assert.Equal(t, models.MercosulPlate("ABC1C34"), "")
+
+	assert.Equal(t, models.OldPlate("ABC1C34"), "ABC-1234")
+	assert.Equal(t, models.OldPlate("xyz9j99"), "XYZ-9999")
+	// K to Z were never old plates
+	assert.Equal(t, models.OldPlate("ABC1K34"), "")
+	assert.Equal(t, models.OldPlate("ABC-1234"), "")
+
+	assert.Equal(t, models.PlateForms("abc 1234"), []string{"ABC-1234", "ABC1C34"})
+	assert.Equal(t, models.PlateForms("ABC1C34"), []string{"ABC1C34", "ABC-1234"})
+	assert.Equal(t, models.PlateForms("ABC1K34"), []string{"ABC1K34"})
+	assert.Equal(t, models.PlateForms("NOTVALID"), []string{"NOTVALID"})
+}
+
+func TestLotFormats(t *testing.T) {
+	// Lots that set no formats keep PLATE_FORMATS
+	var lot models.Lot
+	assert.Equal(t, len(lot.Formats()), 2)
+	assert.Equal(t, lot.NormalizePlate("abc1234"), "ABC-1234")
+
+	argentine := models.Lot{PlateFormats: "ar-mercosul,ar-old"}
+	assert.Equal(t, argentine.NormalizePlate("ab123cd"), "AB 123 CD")
+	assert.Equal(t, argentine.Validate(models.ParkingRequest{Plate: "AB 123 CD"}), true)
+	assert.Equal(t, argentine.Validate(models.ParkingRequest{Plate: "ABC-1234"}), false)
+	assert.Equal(t, models.Validate(models.ParkingRequest{Plate: "AB 123 CD"}), false)
+
+	assert.Equal(t, argentine.Check(models.ParkingRequest{Plate: "ABC-1234"}), []models.FieldError{{
+		Field:   "plate",
+		Rule:    "plate",
+		Value:   "ABC-1234",
+		Message: "must be a plate, formats: AB 123 CD, ABC 123",
+	}})
+
+	errors := models.Check(models.LotRequest{Name: "Lot", PlateFormats: []string{"ar-old", "xx-unknown"}})
+	assert.Equal(t, len(errors), 1)
+	assert.Equal(t, errors[0].Field, "plate_formats[1]")
+	assert.Equal(t, errors[0].Rule, "plateformat")
+}
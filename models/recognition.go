@@ -0,0 +1,52 @@
+package models
+
+// Box is where a plate was found in the image, in pixels from the top left corner
+type Box struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Reading is the text read from an image, confidences go from 0 to 100
+type Reading struct {
+	Text       string      `json:"text"`
+	Confidence float64     `json:"confidence"`
+	Characters []Character `json:"characters,omitempty"`
+}
+
+// Character is a single symbol read and how sure the engine is of it
+type Character struct {
+	Char       string  `json:"char"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Candidate is a plate an image may show
+type Candidate struct {
+	Plate      string  `json:"plate"`
+	Confidence float64 `json:"confidence"`
+	Valid      bool    `json:"valid"`
+	Box        Box     `json:"box"`
+}
+
+// Recognition is the best plate read from an image, where it was and the other readings ranked.
+// Read is the text before it was corrected to a plate layout, Characters are its symbols.
+type Recognition struct {
+	Plate        string      `json:"plate"`
+	Read         string      `json:"read"`
+	Corrected    bool        `json:"corrected"`
+	Confidence   float64     `json:"confidence"`
+	Characters   []Character `json:"characters"`
+	Box          Box         `json:"box"`
+	Alternatives []Candidate `json:"alternatives"`
+}
+
+// CheckinResult is the ticket created from an image, or the review to confirm when the reading was not confident enough.
+// In multi-plate check-ins, Err is why a plate got neither and Error is its message
+type CheckinResult struct {
+	ID     uint   `json:"id,omitempty"`
+	Review string `json:"review,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Err    error  `json:"-"`
+	Recognition
+}
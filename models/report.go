@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// RevenueRow is the revenue of a day for a payment method
+type RevenueRow struct {
+	Day      string `json:"day"`
+	Lot      string `json:"lot"`
+	Method   string `json:"method"`
+	Payments int64  `json:"payments"`
+	Amount   int64  `json:"amount"`
+}
+
+// MovementRow counts the tickets of a day
+type MovementRow struct {
+	Day     string `json:"day"`
+	Lot     string `json:"lot"`
+	Entries int64  `json:"entries"`
+	Exits   int64  `json:"exits"`
+	Open    int64  `json:"open"`
+}
+
+// CategoryRow counts the tickets and sums the payments of a day for a vehicle category
+type CategoryRow struct {
+	Day      string `json:"day"`
+	Lot      string `json:"lot"`
+	Category string `json:"category"`
+	Entries  int64  `json:"entries"`
+	Exits    int64  `json:"exits"`
+	Payments int64  `json:"payments"`
+	Amount   int64  `json:"amount"`
+}
+
+// Analytics describes how the lot was used over a range of days
+type Analytics struct {
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	Dwell    DwellStats    `json:"dwell"`
+	Heatmap  []HeatmapCell `json:"heatmap"`
+	Visitors VisitorStats  `json:"visitors"`
+	Turnover float64       `json:"turnover"`
+}
+
+// DwellStats summarizes, in minutes, how long finished stays took
+type DwellStats struct {
+	Tickets int64   `json:"tickets"`
+	Average float64 `json:"average"`
+	Median  float64 `json:"median"`
+	P95     float64 `json:"p95"`
+}
+
+// HeatmapCell is the average number of cars parked at an hour of the week, weekday 1 is monday
+type HeatmapCell struct {
+	Weekday   int     `json:"weekday"`
+	Hour      int     `json:"hour"`
+	Occupancy float64 `json:"occupancy"`
+}
+
+// VisitorStats counts entries, distinct plates and plates that came more than once
+type VisitorStats struct {
+	Entries  int64 `json:"entries"`
+	Plates   int64 `json:"plates"`
+	Repeated int64 `json:"repeated"`
+}
+
+// ExportRow is a ticket joined with its payment
+type ExportRow struct {
+	ID       uint
+	Plate    string
+	Checkin  time.Time
+	Checkout *time.Time
+	Prepaid  int64
+	Paid     bool
+	Amount   int64
+	Method   string
+	PaidAt   *time.Time
+}
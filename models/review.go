@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Review statuses, an accepted review took the best reading and an edited one a plate typed by the operator
+const (
+	ReviewPending  = "pending"
+	ReviewAccepted = "accepted"
+	ReviewEdited   = "edited"
+	ReviewRejected = "rejected"
+)
+
+// Review is an image check-in that could not be read with confidence, it waits for an operator to resolve the plate
+type Review struct {
+	gorm.Model
+	LotID      uint   `gorm:"index"`
+	Token      string `gorm:"uniqueIndex"`
+	Image      string // key in the image store
+	Plate      string
+	Confidence float64
+	Candidates string
+	Status     string `gorm:"index"`
+	CapturedAt time.Time
+	ResolvedAt *time.Time
+	ParkingID  *uint
+}
+
+// ReviewRequest edits the plate of a review
+type ReviewRequest struct {
+	Plate string `json:"plate"`
+}
+
+// ReviewEntry is a review as shown to operators, resolution is how long it waited
+type ReviewEntry struct {
+	Token             string      `json:"token"`
+	Status            string      `json:"status"`
+	Plate             string      `json:"plate"`
+	Confidence        float64     `json:"confidence"`
+	Candidates        []Candidate `json:"candidates"`
+	CapturedAt        time.Time   `json:"captured_at"`
+	ResolvedAt        *time.Time  `json:"resolved_at,omitempty"`
+	ResolutionSeconds int64       `json:"resolution_seconds,omitempty"`
+	ParkingID         *uint       `json:"parking_id,omitempty"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Tariff holds the prices, in cents, charged for a stay
+type Tariff struct {
+	Grace          time.Duration
+	FirstHour      int64
+	AdditionalHour int64
+	DailyMax       int64
+}
+
+// TariffLine is one line of a charge breakdown
+type TariffLine struct {
+	Description string `json:"description"`
+	Quantity    int64  `json:"quantity"`
+	Amount      int64  `json:"amount"`
+}
+
+// Charge breaks down the price of a stay, stays shorter than the grace period are free
+func (t Tariff) Charge(stay time.Duration) []TariffLine {
+	if stay < t.Grace {
+		return []TariffLine{{Description: "Grace period", Quantity: 1, Amount: 0}}
+	}
+
+	hours := int64(math.Ceil(stay.Hours()))
+	if hours < 1 {
+		hours = 1
+	}
+
+	lines := []TariffLine{}
+	if days := hours / 24; days > 0 {
+		lines = append(lines, TariffLine{Description: "Full day", Quantity: days, Amount: days * t.day(24)})
+	}
+
+	rest := hours % 24
+	if rest == 0 {
+		return lines
+	}
+
+	if t.DailyMax > 0 && t.hours(rest) > t.DailyMax {
+		return append(lines, TariffLine{Description: "Daily maximum", Quantity: 1, Amount: t.DailyMax})
+	}
+
+	lines = append(lines, TariffLine{Description: "First hour", Quantity: 1, Amount: t.FirstHour})
+	if rest > 1 {
+		lines = append(lines, TariffLine{Description: "Additional hour", Quantity: rest - 1, Amount: (rest - 1) * t.AdditionalHour})
+	}
+
+	return lines
+}
+
+// Total sums the breakdown lines
+func Total(lines []TariffLine) int64 {
+	var total int64
+	for _, line := range lines {
+		total += line.Amount
+	}
+
+	return total
+}
+
+func (t Tariff) day(hours int64) int64 {
+	price := t.hours(hours)
+	if t.DailyMax > 0 && price > t.DailyMax {
+		return t.DailyMax
+	}
+
+	return price
+}
+
+func (t Tariff) hours(hours int64) int64 {
+	return t.FirstHour + (hours-1)*t.AdditionalHour
+}
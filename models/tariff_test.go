@@ -0,0 +1,40 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"br.com.mlabs/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCharge(t *testing.T) {
+	tariff := models.Tariff{
+		Grace:          15 * time.Minute,
+		FirstHour:      1000,
+		AdditionalHour: 500,
+		DailyMax:       4000,
+	}
+
+	lines := tariff.Charge(10 * time.Minute)
+	assert.Equal(t, lines, []models.TariffLine{{Description: "Grace period", Quantity: 1, Amount: 0}})
+
+	lines = tariff.Charge(50 * time.Minute)
+	assert.Equal(t, lines, []models.TariffLine{{Description: "First hour", Quantity: 1, Amount: 1000}})
+
+	lines = tariff.Charge(150 * time.Minute)
+	assert.Equal(t, lines, []models.TariffLine{
+		{Description: "First hour", Quantity: 1, Amount: 1000},
+		{Description: "Additional hour", Quantity: 2, Amount: 1000},
+	})
+	assert.Equal(t, models.Total(lines), int64(2000))
+
+	lines = tariff.Charge(10 * time.Hour)
+	assert.Equal(t, lines, []models.TariffLine{{Description: "Daily maximum", Quantity: 1, Amount: 4000}})
+
+	lines = tariff.Charge(49 * time.Hour)
+	assert.Equal(t, lines, []models.TariffLine{
+		{Description: "Full day", Quantity: 2, Amount: 8000},
+		{Description: "First hour", Quantity: 1, Amount: 1000},
+	})
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"br.com.mlabs/plates"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// validate is built once, it caches struct rules and is safe for concurrent use
+var validate = newValidator()
+
+// FieldError is a field that failed validation, named as in JSON
+type FieldError struct {
+	Field   string      `json:"field"`
+	Rule    string      `json:"rule"`
+	Value   interface{} `json:"value"`
+	Message string      `json:"message"`
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidationCtx("plate", plateValidation, false)
+	v.RegisterValidation("plateformat", plateFormatValidation, false)
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	return v
+}
+
+// Validate returns true if the request is valid
+func Validate(model interface{}) bool {
+	return len(Check(model)) == 0
+}
+
+// Invalid is a request that failed validation, Err is how it is reported and Fields are what failed
+type Invalid struct {
+	Err    error
+	Fields []FieldError
+}
+
+func (i Invalid) Error() string {
+	return i.Err.Error()
+}
+
+// Unwrap lets errors.Is match Err
+func (i Invalid) Unwrap() error {
+	return i.Err
+}
+
+// Verify checks a request, it is err with the fields that failed, or nil when it is valid
+func Verify(model interface{}, err error) error {
+	if fields := Check(model); fields != nil {
+		return Invalid{Err: err, Fields: fields}
+	}
+	return nil
+}
+
+// formatsKey holds the plate formats a request is validated against in its context
+type formatsKey struct{}
+
+// Check lists the fields of a request that failed validation, nil when it is valid.
+// Plates are checked against PLATE_FORMATS, Lot.Check checks them against the formats of a lot
+func Check(model interface{}) []FieldError {
+	return check(plates.Enabled(), model)
+}
+
+func check(formats []plates.Format, model interface{}) []FieldError {
+	err := validate.StructCtx(context.WithValue(context.Background(), formatsKey{}, formats), model)
+	if err == nil {
+		return nil
+	}
+
+	valErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		logrus.Warnf("Validation failed for: %v %s", model, err.Error())
+		return []FieldError{{Rule: "struct", Message: err.Error()}}
+	}
+	logrus.Warnf("Validation failed for: %v %s", model, valErrors.Error())
+
+	var res []FieldError
+	for _, e := range valErrors {
+		res = append(res, FieldError{
+			Field:   fieldPath(e.Namespace()),
+			Rule:    e.Tag(),
+			Value:   e.Value(),
+			Message: message(e, formats),
+		})
+	}
+
+	return res
+}
+
+// fieldPath drops the struct name from a namespace like ImportRequest.events[0].plate
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// message says what a rule expects, in words a client can show
+func message(e validator.FieldError, formats []plates.Format) string {
+	switch e.Tag() {
+	case "plate":
+		return fmt.Sprintf("must be a plate, formats: %s", plates.ExamplesOf(formats))
+	case "plateformat":
+		var ids []string
+		for _, format := range plates.All() {
+			ids = append(ids, format.ID)
+		}
+		return fmt.Sprintf("must be a plate format: %s", strings.Join(ids, ", "))
+	case "required":
+		return "is required"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.Replace(e.Param(), " ", ", ", -1))
+	case "min":
+		return fmt.Sprintf("must have at least %s items", e.Param())
+	case "max":
+		if e.Kind() == reflect.String {
+			return fmt.Sprintf("must have at most %s characters", e.Param())
+		}
+		return fmt.Sprintf("must have at most %s items", e.Param())
+	case "gte":
+		return fmt.Sprintf("must be at least %s", e.Param())
+	case "gtfield":
+		return fmt.Sprintf("must be after %s", e.Param())
+	}
+
+	return fmt.Sprintf("failed the %s rule", e.Tag())
+}
+
+// plateValidation accepts plates written in one of the formats in the context
+func plateValidation(ctx context.Context, fl validator.FieldLevel) bool {
+	formats, _ := ctx.Value(formatsKey{}).([]plates.Format)
+	_, ok := plates.FindIn(formats, fl.Field().String())
+	return ok
+}
+
+// plateFormatValidation accepts the id of a known plate format
+func plateFormatValidation(fl validator.FieldLevel) bool {
+	_, ok := plates.Get(fl.Field().String())
+	return ok
+}
@@ -0,0 +1,277 @@
+package plates
+
+import (
+	"strings"
+	"sync"
+)
+
+// Format ids in the registry
+const (
+	BROld      = "br-old"
+	BRMercosul = "br-mercosul"
+	AROld      = "ar-old"
+	ARMercosul = "ar-mercosul"
+	UYMercosul = "uy-mercosul"
+	PYMercosul = "py-mercosul"
+	// ARMercosulMoto is the Argentine Mercosul plate of motorcycles
+	ARMercosulMoto = "ar-mercosul-moto"
+)
+
+// Format is a plate layout of a country
+type Format struct {
+	ID string `json:"id"`
+	// Country is the ISO 3166 code of the country issuing it
+	Country string `json:"country"`
+	// Template is how the plate is written, L is a letter, D a digit and anything else is kept as it is
+	Template string `json:"template"`
+	// Example is a plate in this format, shown to users
+	Example string `json:"example"`
+	// Vehicle is the only vehicle category issued this format, empty when any vehicle is
+	Vehicle string `json:"vehicle,omitempty"`
+}
+
+// registry is every known format, the order breaks ties when a plate fits more than one
+var registry = []Format{
+	{ID: BROld, Country: "BR", Template: "LLL-DDDD", Example: "ABC-1234"},
+	{ID: BRMercosul, Country: "BR", Template: "LLLDLDD", Example: "ABC1D23"},
+	{ID: ARMercosul, Country: "AR", Template: "LL DDD LL", Example: "AB 123 CD"},
+	{ID: AROld, Country: "AR", Template: "LLL DDD", Example: "ABC 123"},
+	{ID: UYMercosul, Country: "UY", Template: "LLL DDDD", Example: "ABC 1234"},
+	{ID: PYMercosul, Country: "PY", Template: "LLLL DDD", Example: "ABCD 123"},
+	{ID: ARMercosulMoto, Country: "AR", Template: "L DDD LLL", Example: "A 123 BCD", Vehicle: "motorcycle"},
+}
+
+// Default is the formats enabled when PLATE_FORMATS sets none
+var Default = []string{BROld, BRMercosul}
+
+var (
+	enabled      []Format
+	enabledMutex sync.RWMutex
+)
+
+func init() {
+	for _, id := range Default {
+		format, _ := Get(id)
+		enabled = append(enabled, format)
+	}
+}
+
+// All is the registry, in its order
+func All() []Format {
+	return append([]Format(nil), registry...)
+}
+
+// Get finds a format by id
+func Get(id string) (Format, bool) {
+	for _, format := range registry {
+		if format.ID == id {
+			return format, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// Formats is the known formats of the ids, in their order, unknown ids are left out
+func Formats(ids []string) []Format {
+	var res []Format
+	for _, id := range ids {
+		if format, ok := Get(strings.TrimSpace(id)); ok {
+			res = append(res, format)
+		}
+	}
+
+	return res
+}
+
+// Enable sets the formats of lots that set none, in the order ties are broken
+func Enable(formats []Format) {
+	enabledMutex.Lock()
+	defer enabledMutex.Unlock()
+
+	enabled = append([]Format(nil), formats...)
+}
+
+// Enabled is the formats of lots that set none
+func Enabled() []Format {
+	enabledMutex.RLock()
+	defer enabledMutex.RUnlock()
+
+	return append([]Format(nil), enabled...)
+}
+
+// Examples lists an example of each enabled format, for error messages
+func Examples() string {
+	return ExamplesOf(Enabled())
+}
+
+// ExamplesOf lists an example of each format
+func ExamplesOf(formats []Format) string {
+	var examples []string
+	for _, format := range formats {
+		examples = append(examples, format.Example)
+	}
+
+	return strings.Join(examples, ", ")
+}
+
+// Mask is the template without its separators, the layout OCR fits characters to
+func (f Format) Mask() string {
+	var mask []rune
+	for _, c := range f.Template {
+		if c == 'L' || c == 'D' {
+			mask = append(mask, c)
+		}
+	}
+
+	return string(mask)
+}
+
+// Match tells if a plate is written exactly as the template
+func (f Format) Match(plate string) bool {
+	chars := []rune(plate)
+	template := []rune(f.Template)
+	if len(chars) != len(template) {
+		return false
+	}
+
+	for i, t := range template {
+		c := chars[i]
+		switch t {
+		case 'L':
+			if c < 'A' || c > 'Z' {
+				return false
+			}
+		case 'D':
+			if c < '0' || c > '9' {
+				return false
+			}
+		default:
+			if c != t {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Render writes characters fitting the mask with the template's separators
+func (f Format) Render(chars string) string {
+	src := []rune(chars)
+	var res []rune
+	i := 0
+	for _, t := range f.Template {
+		if t != 'L' && t != 'D' {
+			res = append(res, t)
+			continue
+		}
+		if i < len(src) {
+			res = append(res, src[i])
+		}
+		i++
+	}
+
+	return string(res)
+}
+
+// Separators scores how well the separators in a text match the template: 2 when they are the same,
+// 1 when they are at the same places but written differently, like a space for a dash, and 0 otherwise
+func (f Format) Separators(text string) int {
+	textPlaces, textKinds := separators(text)
+	templatePlaces, templateKinds := separators(f.Template)
+
+	switch {
+	case textPlaces != templatePlaces:
+		return 0
+	case textKinds != templateKinds:
+		return 1
+	}
+
+	return 2
+}
+
+// separators describes where a text has separators, by the number of characters before each, and which ones
+func separators(text string) (string, string) {
+	var places, kinds []string
+	count := 0
+	for _, c := range text {
+		if IsSeparator(c) {
+			places = append(places, string(rune('0'+count)))
+			kinds = append(kinds, string(c))
+			continue
+		}
+		count++
+	}
+
+	return strings.Join(places, ","), strings.Join(kinds, "")
+}
+
+// IsSeparator tells if a character only separates the groups of a plate
+func IsSeparator(c rune) bool {
+	return c == ' ' || c == '-' || c == '.'
+}
+
+// Find is the enabled format a plate is written in
+func Find(plate string) (Format, bool) {
+	return FindIn(Enabled(), plate)
+}
+
+// FindIn is the format a plate is written in, out of formats
+func FindIn(formats []Format, plate string) (Format, bool) {
+	for _, format := range formats {
+		if format.Match(plate) {
+			return format, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// Lookup is the registered format a plate is written in, enabled or not, for plates already stored
+func Lookup(plate string) (Format, bool) {
+	for _, format := range registry {
+		if format.Match(plate) {
+			return format, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// Normalize writes a plate as typed in the enabled format it fits, ignoring case and separators.
+// When it fits more than one, the one written with the same separators wins, then the first enabled.
+// Plates that fit none are only trimmed and upper-cased, so they fail validation
+func Normalize(plate string) (string, Format, bool) {
+	return NormalizeIn(Enabled(), plate)
+}
+
+// NormalizeIn is Normalize out of formats
+func NormalizeIn(formats []Format, plate string) (string, Format, bool) {
+	plate = strings.ToUpper(strings.TrimSpace(plate))
+
+	var chars []rune
+	for _, c := range plate {
+		if IsSeparator(c) || c == '\t' || c == '\n' || c == '\r' {
+			continue
+		}
+		chars = append(chars, c)
+	}
+
+	best, score := Format{}, -1
+	for _, format := range formats {
+		candidate := format.Render(string(chars))
+		if len([]rune(format.Mask())) != len(chars) || !format.Match(candidate) {
+			continue
+		}
+		if s := format.Separators(plate); s > score {
+			best, score = format, s
+		}
+	}
+
+	if score < 0 {
+		return plate, Format{}, false
+	}
+
+	return best.Render(string(chars)), best, true
+}
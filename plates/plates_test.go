@@ -0,0 +1,131 @@
+package plates_test
+
+import (
+	"testing"
+
+	"br.com.mlabs/plates"
+	"github.com/stretchr/testify/assert"
+)
+
+// enable turns on formats by id, the returned func restores the default
+func enable(t *testing.T, ids ...string) func() {
+	var formats []plates.Format
+	for _, id := range ids {
+		format, ok := plates.Get(id)
+		if !ok {
+			t.Fatalf("unknown format %s", id)
+		}
+		formats = append(formats, format)
+	}
+	plates.Enable(formats)
+
+	return func() {
+		enable(t, plates.Default...)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for _, format := range plates.All() {
+		// Examples are written in their own format, and only match it
+		lookup, ok := plates.Lookup(format.Example)
+		assert.Equal(t, ok, true, format.ID)
+		assert.Equal(t, lookup.ID, format.ID)
+	}
+
+	_, ok := plates.Get("xx-unknown")
+	assert.Equal(t, ok, false)
+
+	uy, _ := plates.Get(plates.UYMercosul)
+	assert.Equal(t, uy.Country, "UY")
+	assert.Equal(t, uy.Mask(), "LLLDDDD")
+	assert.Equal(t, uy.Render("ABC1234"), "ABC 1234")
+	assert.Equal(t, uy.Match("ABC 1234"), true)
+	assert.Equal(t, uy.Match("ABC-1234"), false)
+	assert.Equal(t, uy.Vehicle, "")
+
+	moto, _ := plates.Get(plates.ARMercosulMoto)
+	assert.Equal(t, moto.Vehicle, "motorcycle")
+	assert.Equal(t, moto.Render("A123BCD"), "A 123 BCD")
+}
+
+func TestFind(t *testing.T) {
+	format, ok := plates.Find("ABC-1234")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, format.ID, plates.BROld)
+
+	// Known, but not accepted by this lot
+	_, ok = plates.Find("AB 123 CD")
+	assert.Equal(t, ok, false)
+	format, ok = plates.Lookup("AB 123 CD")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, format.Country, "AR")
+
+	defer enable(t, plates.BRMercosul, plates.ARMercosul, plates.AROld)()
+	format, ok = plates.Find("AB 123 CD")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, format.ID, plates.ARMercosul)
+	_, ok = plates.Find("ABC-1234")
+	assert.Equal(t, ok, false)
+
+	assert.Equal(t, plates.Examples(), "ABC1D23, AB 123 CD, ABC 123")
+}
+
+func TestFindIn(t *testing.T) {
+	formats := plates.Formats([]string{plates.ARMercosul, "xx-unknown", plates.AROld})
+	assert.Equal(t, len(formats), 2)
+	assert.Equal(t, plates.ExamplesOf(formats), "AB 123 CD, ABC 123")
+
+	// Whatever is enabled, only the given formats count
+	format, ok := plates.FindIn(formats, "AB 123 CD")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, format.ID, plates.ARMercosul)
+	_, ok = plates.FindIn(formats, "ABC-1234")
+	assert.Equal(t, ok, false)
+
+	plate, format, ok := plates.NormalizeIn(formats, "abc.123")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, plate, "ABC 123")
+	assert.Equal(t, format.ID, plates.AROld)
+
+	assert.Equal(t, plates.Formats(nil), []plates.Format(nil))
+}
+
+func TestNormalize(t *testing.T) {
+	defer enable(t, plates.BROld, plates.BRMercosul, plates.ARMercosul, plates.AROld, plates.UYMercosul, plates.PYMercosul)()
+
+	cases := []struct {
+		input  string
+		plate  string
+		format string
+	}{
+		{"abc-1234", "ABC-1234", plates.BROld},
+		{"ABC1234", "ABC-1234", plates.BROld},
+		{"ABC1D23", "ABC1D23", plates.BRMercosul},
+		{"ab123cd", "AB 123 CD", plates.ARMercosul},
+		{"AB-123-CD", "AB 123 CD", plates.ARMercosul},
+		{"abc.123", "ABC 123", plates.AROld},
+		{"abcd 123", "ABCD 123", plates.PYMercosul},
+		// Uruguay and old Brazil share a mask, how it was written breaks the tie
+		{"ABC 1234", "ABC 1234", plates.UYMercosul},
+		{"ABC-1234", "ABC-1234", plates.BROld},
+	}
+
+	for _, c := range cases {
+		plate, format, ok := plates.Normalize(c.input)
+		assert.Equal(t, ok, true, c.input)
+		assert.Equal(t, plate, c.plate, c.input)
+		assert.Equal(t, format.ID, c.format, c.input)
+	}
+
+	plate, _, ok := plates.Normalize(" abc 12 ")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, plate, "ABC 12")
+}
+
+func TestSeparators(t *testing.T) {
+	old, _ := plates.Get(plates.BROld)
+	assert.Equal(t, old.Separators("ABC-1234"), 2)
+	assert.Equal(t, old.Separators("ABC 1234"), 1)
+	assert.Equal(t, old.Separators("ABC1234"), 0)
+	assert.Equal(t, old.Separators("AB-C1234"), 0)
+}
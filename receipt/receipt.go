@@ -0,0 +1,170 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"br.com.mlabs/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+const (
+	timeFormat = "02/01/2006 15:04"
+	// Width is the number of columns of an 80mm thermal printer
+	Width = 42
+)
+
+// Receipt holds what is printed on a payment receipt
+type Receipt struct {
+	Lot      string
+	Number   uint
+	Plate    string
+	Checkin  time.Time
+	Checkout *time.Time
+	PaidAt   time.Time
+	Lines    []models.TariffLine
+	Prepaid  int64
+	Amount   int64
+	Method   string
+}
+
+// Duration is the stay that was charged
+func (r Receipt) Duration() time.Duration {
+	return r.PaidAt.Sub(r.Checkin)
+}
+
+// Text renders the receipt as fixed width text for thermal printers
+func Text(r Receipt) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(center(r.Lot) + "\n")
+	buf.WriteString(center(fmt.Sprintf("Receipt %s", number(r.Number))) + "\n")
+	buf.WriteString(strings.Repeat("-", Width) + "\n")
+
+	for _, row := range header(r) {
+		buf.WriteString(columns(row[0], row[1]) + "\n")
+	}
+	buf.WriteString(strings.Repeat("-", Width) + "\n")
+
+	for _, row := range breakdown(r) {
+		buf.WriteString(columns(row[0], row[1]) + "\n")
+	}
+	buf.WriteString(strings.Repeat("-", Width) + "\n")
+
+	buf.WriteString(columns("TOTAL", Money(r.Amount)) + "\n")
+	buf.WriteString(columns("Method", r.Method) + "\n")
+
+	return buf.Bytes()
+}
+
+// PDF renders the receipt as an 80mm wide PDF page
+func PDF(r Receipt) ([]byte, error) {
+	rows := breakdown(r)
+
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: 80, Ht: float64(110 + 6*len(rows))},
+	})
+	pdf.SetMargins(5, 5, 5)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(70, 8, translate(r.Lot), "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(70, 6, "Receipt "+number(r.Number), "B", 1, "C", false, 0, "")
+
+	for _, row := range header(r) {
+		pdf.CellFormat(30, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, row[1], "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(2)
+
+	for _, row := range rows {
+		pdf.CellFormat(45, 6, translate(row[0]), "", 0, "L", false, 0, "")
+		pdf.CellFormat(25, 6, translate(row[1]), "", 1, "R", false, 0, "")
+	}
+
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(45, 8, "TOTAL", "T", 0, "L", false, 0, "")
+	pdf.CellFormat(25, 8, translate(Money(r.Amount)), "T", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(45, 6, "Method", "", 0, "L", false, 0, "")
+	pdf.CellFormat(25, 6, r.Method, "", 1, "R", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Money formats cents as Brazilian reais
+func Money(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	return fmt.Sprintf("%sR$ %d,%02d", sign, cents/100, cents%100)
+}
+
+func header(r Receipt) [][2]string {
+	checkout := "-"
+	if r.Checkout != nil {
+		checkout = r.Checkout.Format(timeFormat)
+	}
+
+	return [][2]string{
+		{"Plate", r.Plate},
+		{"Check-in", r.Checkin.Format(timeFormat)},
+		{"Check-out", checkout},
+		{"Paid at", r.PaidAt.Format(timeFormat)},
+		{"Duration", duration(r.Duration())},
+	}
+}
+
+func breakdown(r Receipt) [][2]string {
+	rows := [][2]string{}
+	for _, line := range r.Lines {
+		rows = append(rows, [2]string{fmt.Sprintf("%dx %s", line.Quantity, line.Description), Money(line.Amount)})
+	}
+	if r.Prepaid > 0 {
+		rows = append(rows, [2]string{"Prepaid booking", Money(-r.Prepaid)})
+	}
+
+	return rows
+}
+
+func number(n uint) string {
+	return fmt.Sprintf("%08d", n)
+}
+
+func duration(d time.Duration) string {
+	minutes := int64(d.Minutes())
+
+	return fmt.Sprintf("%dh%02dmin", minutes/60, minutes%60)
+}
+
+func center(str string) string {
+	size := len([]rune(str))
+	if size >= Width {
+		return str
+	}
+
+	return strings.Repeat(" ", (Width-size)/2) + str
+}
+
+func columns(left string, right string) string {
+	space := Width - len([]rune(left)) - len([]rune(right))
+	if space < 1 {
+		space = 1
+	}
+
+	return left + strings.Repeat(" ", space) + right
+}
@@ -0,0 +1,49 @@
+package receipt_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/receipt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoney(t *testing.T) {
+	assert.Equal(t, receipt.Money(0), "R$ 0,00")
+	assert.Equal(t, receipt.Money(1250), "R$ 12,50")
+	assert.Equal(t, receipt.Money(-305), "-R$ 3,05")
+}
+
+func TestRender(t *testing.T) {
+	checkin := time.Date(2020, 11, 10, 14, 0, 0, 0, time.UTC)
+	rcpt := receipt.Receipt{
+		Lot:     "Estacionamento",
+		Number:  7,
+		Plate:   "ABC-1234",
+		Checkin: checkin,
+		PaidAt:  checkin.Add(150 * time.Minute),
+		Lines: []models.TariffLine{
+			{Description: "First hour", Quantity: 1, Amount: 1000},
+			{Description: "Additional hour", Quantity: 2, Amount: 1000},
+		},
+		Prepaid: 500,
+		Amount:  1500,
+		Method:  models.PaymentPix,
+	}
+
+	text := string(receipt.Text(rcpt))
+	assert.Equal(t, strings.Contains(text, "Receipt 00000007"), true)
+	assert.Equal(t, strings.Contains(text, "Duration                           2h30min"), true)
+	assert.Equal(t, strings.Contains(text, "Prepaid booking                   -R$ 5,00"), true)
+	assert.Equal(t, strings.Contains(text, "TOTAL                             R$ 15,00"), true)
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		assert.LessOrEqual(t, len([]rune(line)), receipt.Width)
+	}
+
+	pdf, err := receipt.PDF(rcpt)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bytes.HasPrefix(pdf, []byte("%PDF")), true)
+}
@@ -0,0 +1,114 @@
+package recognition
+
+import (
+	"sort"
+	"strings"
+
+	"br.com.mlabs/plates"
+)
+
+// Characters OCR mixes up, by what they should be in a letter or a digit position
+var (
+	asLetter = map[rune]rune{'0': 'O', '1': 'I', '8': 'B', '5': 'S', '2': 'Z', '6': 'G'}
+	asDigit  = map[rune]rune{'O': '0', 'I': '1', 'B': '8', 'S': '5', 'Z': '2', 'G': '6'}
+)
+
+// Correct fits the text read from a plate to the enabled plate formats, like the old (AAA-9999) or the Mercosul (AAA9A99) layout,
+// swapping look-alike characters that are in the wrong kind of position and adding the format's separators.
+// The format needing the fewest swaps wins, on a tie the one whose separators are in the text, then the first enabled.
+// Text that fits none is returned as it was.
+func Correct(text string) (string, bool) {
+	fits := Fits(text)
+	if len(fits) == 0 {
+		return text, false
+	}
+
+	return fits[0].Plate, fits[0].Plate != text
+}
+
+// Fit is a plate the text can be corrected to and how many characters were swapped for it
+type Fit struct {
+	Plate string
+	Swaps int
+}
+
+// Fits lists the plates the text can be corrected to, in the order Correct prefers them
+func Fits(text string) []Fit {
+	return FitsIn(plates.Enabled(), text)
+}
+
+// FitsIn is Fits to formats, like those of a lot
+func FitsIn(formats []plates.Format, text string) []Fit {
+	upper := strings.ToUpper(text)
+
+	var chars []rune
+	for _, c := range upper {
+		if !plates.IsSeparator(c) {
+			chars = append(chars, c)
+		}
+	}
+
+	type scored struct {
+		fit        Fit
+		separators int
+	}
+
+	var res []scored
+	for _, format := range formats {
+		mask := format.Mask()
+		if len(mask) != len(chars) {
+			continue
+		}
+
+		fitted, swaps := fit(chars, mask)
+		if swaps < 0 {
+			continue
+		}
+
+		res = append(res, scored{
+			fit:        Fit{Plate: format.Render(fitted), Swaps: swaps},
+			separators: format.Separators(upper),
+		})
+	}
+
+	sort.SliceStable(res, func(i int, j int) bool {
+		if res[i].fit.Swaps != res[j].fit.Swaps {
+			return res[i].fit.Swaps < res[j].fit.Swaps
+		}
+		return res[i].separators > res[j].separators
+	})
+
+	var fits []Fit
+	for _, r := range res {
+		fits = append(fits, r.fit)
+	}
+
+	return fits
+}
+
+// fit swaps the characters that do not match the mask, the count is -1 when some character has no swap
+func fit(chars []rune, mask string) (string, int) {
+	res := make([]rune, len(chars))
+	swaps := 0
+	for i, c := range chars {
+		letter := c >= 'A' && c <= 'Z'
+		digit := c >= '0' && c <= '9'
+
+		switch {
+		case mask[i] == 'L' && letter, mask[i] == 'D' && digit:
+			res[i] = c
+			continue
+		case mask[i] == 'L':
+			res[i] = asLetter[c]
+		default:
+			res[i] = asDigit[c]
+		}
+
+		if res[i] == 0 {
+			return "", -1
+		}
+		swaps++
+	}
+
+	return string(res), swaps
+}
@@ -0,0 +1,80 @@
+package recognition_test
+
+import (
+	"testing"
+
+	"br.com.mlabs/plates"
+	"br.com.mlabs/recognition"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrect(t *testing.T) {
+	cases := []struct {
+		text      string
+		plate     string
+		corrected bool
+	}{
+		{"GTJ-6699", "GTJ-6699", false},
+		{"BRA3R52", "BRA3R52", false},
+		{"GTJ-66O9", "GTJ-6609", true},
+		{"8RA3R52", "BRA3R52", true},
+		{"GTJ6699", "GTJ-6699", true},
+		{"gtj6699", "GTJ-6699", true},
+		{"6TJ-55I2", "GTJ-5512", true},
+		{"6TJ-SSI2", "GTJ5S12", true},
+		{"BRA3RS2", "BRA3R52", true},
+		{"BRA-3Z52", "BRA3Z52", true},
+		{"BRA3Z52", "BRA3Z52", false},
+		{"0BC1D2Z", "OBC1D22", true},
+		{"SPGUARULHOS", "SPGUARULHOS", false},
+		{"ABC-12X4", "ABC-12X4", false},
+		{"", "", false},
+	}
+
+	for _, c := range cases {
+		plate, corrected := recognition.Correct(c.text)
+		assert.Equal(t, plate, c.plate, c.text)
+		assert.Equal(t, corrected, c.corrected, c.text)
+	}
+}
+
+func TestFits(t *testing.T) {
+	assert.Equal(t, recognition.Fits("GTJ-66O9"), []recognition.Fit{{Plate: "GTJ-6609", Swaps: 1}, {Plate: "GTJ6G09", Swaps: 2}})
+	assert.Equal(t, recognition.Fits("BRA3R52"), []recognition.Fit{{Plate: "BRA3R52", Swaps: 0}})
+	assert.Equal(t, recognition.Fits("ABC1234"), []recognition.Fit{{Plate: "ABC-1234", Swaps: 0}, {Plate: "ABC1Z34", Swaps: 1}})
+	assert.Equal(t, recognition.Fits("SPGUARULHOS"), []recognition.Fit(nil))
+}
+
+func TestFitsEnabledFormats(t *testing.T) {
+	var formats []plates.Format
+	for _, id := range []string{plates.ARMercosul, plates.AROld, plates.BRMercosul} {
+		format, _ := plates.Get(id)
+		formats = append(formats, format)
+	}
+	plates.Enable(formats)
+	defer func() {
+		var formats []plates.Format
+		for _, id := range plates.Default {
+			format, _ := plates.Get(id)
+			formats = append(formats, format)
+		}
+		plates.Enable(formats)
+	}()
+
+	assert.Equal(t, recognition.Fits("AB123CD"), []recognition.Fit{{Plate: "AB 123 CD", Swaps: 0}})
+	assert.Equal(t, recognition.Fits("A8 I23 C0"), []recognition.Fit{{Plate: "AB 123 CO", Swaps: 3}})
+	assert.Equal(t, recognition.Fits("ABC123"), []recognition.Fit{{Plate: "ABC 123", Swaps: 0}})
+
+	// The old Brazilian layout is not enabled
+	plate, corrected := recognition.Correct("GTJ6699")
+	assert.Equal(t, plate, "GTJ6G99")
+	assert.Equal(t, corrected, true)
+}
+
+func TestFitsIn(t *testing.T) {
+	// A lot's own formats, whatever is enabled
+	formats := plates.Formats([]string{plates.ARMercosul})
+	assert.Equal(t, recognition.FitsIn(formats, "A8 I23 C0"), []recognition.Fit{{Plate: "AB 123 CO", Swaps: 3}})
+	assert.Equal(t, recognition.FitsIn(formats, "GTJ-6699"), []recognition.Fit(nil))
+	assert.Equal(t, recognition.Fits("GTJ-6699"), []recognition.Fit{{Plate: "GTJ-6699", Swaps: 0}, {Plate: "GTJ6G99", Swaps: 1}})
+}
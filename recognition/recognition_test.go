@@ -0,0 +1,65 @@
+package recognition_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/recognition"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func readAsset(t *testing.T, name string) []byte {
+	image, err := ioutil.ReadFile(filepath.Join("..", "assets", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return image
+}
+
+func TestFake(t *testing.T) {
+	image := readAsset(t, "download.jpg")
+	fake := recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(image): recognition.Certain("GTJ-6699"),
+	})
+
+	reading, err := fake.Recognize(image)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, reading.Text, "GTJ-6699")
+	assert.Equal(t, reading.Confidence, 100.0)
+	assert.Equal(t, len(reading.Characters), 8)
+
+	_, err = fake.Recognize(readAsset(t, "download2.jpg"))
+	assert.Equal(t, err, utils.ErrImageRecognition)
+}
+
+func TestLoadFake(t *testing.T) {
+	image := readAsset(t, "download2.jpg")
+	blurry := readAsset(t, "download.jpg")
+
+	file, err := ioutil.TempFile("", "plates-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.WriteString(`{
+		"` + recognition.Hash(image) + `": "BRA3R52",
+		"` + recognition.Hash(blurry) + `": {"text": "GTJ-6G99", "confidence": 41.5}
+	}`)
+
+	recognizer, err := recognition.LoadFake(file.Name())
+	assert.Equal(t, err, nil)
+
+	reading, err := recognizer.Recognize(image)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, reading, recognition.Certain("BRA3R52"))
+
+	reading, err = recognizer.Recognize(blurry)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, reading, models.Reading{Text: "GTJ-6G99", Confidence: 41.5})
+
+	_, err = recognition.LoadFake("notfound.json")
+	assert.NotEqual(t, err, nil)
+}
@@ -0,0 +1,49 @@
+package tesseract
+
+import (
+	"strings"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+	"github.com/otiai10/gosseract"
+)
+
+// Recognizer recognizes plates with the tesseract OCR library, it needs the tesseract headers so only main imports it
+type Recognizer struct{}
+
+// Recognize runs tesseract over the image, the overall confidence is the mean of the characters'
+func (Recognizer) Recognize(image []byte) (models.Reading, error) {
+	client := gosseract.NewClient()
+	defer client.Close()
+
+	if err := client.SetImageFromBytes(image); err != nil {
+		return models.Reading{}, utils.ErrImageRecognition
+	}
+
+	symbols, err := client.GetBoundingBoxes(gosseract.RIL_SYMBOL)
+	if err != nil {
+		return models.Reading{}, utils.ErrImageRecognition
+	}
+
+	var reading models.Reading
+	var text strings.Builder
+	for _, symbol := range symbols {
+		char := strings.TrimSpace(symbol.Word)
+		if char == "" {
+			continue
+		}
+
+		text.WriteString(char)
+		reading.Characters = append(reading.Characters, models.Character{Char: char, Confidence: symbol.Confidence})
+		reading.Confidence += symbol.Confidence
+	}
+
+	if len(reading.Characters) == 0 {
+		return models.Reading{}, utils.ErrImageRecognition
+	}
+
+	reading.Text = text.String()
+	reading.Confidence /= float64(len(reading.Characters))
+
+	return reading, nil
+}
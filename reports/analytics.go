@@ -0,0 +1,52 @@
+package reports
+
+import (
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+)
+
+// GetAnalytics gets dwell times, the hour of week heatmap, visitors and the turnover per space of a lot
+func GetAnalytics(lot models.Lot, r Range) (models.Analytics, error) {
+	tz := config.Timezone()
+	analytics := models.Analytics{From: r.From, To: r.To}
+
+	var err error
+	analytics.Dwell, err = storage.DwellTime(lot.ID, r.From, r.To, tz)
+	if err != nil {
+		return analytics, err
+	}
+
+	analytics.Heatmap, err = storage.OccupancyHeatmap(lot.ID, r.From, r.To, tz)
+	if err != nil {
+		return analytics, err
+	}
+
+	analytics.Visitors, err = storage.Visitors(lot.ID, r.From, r.To, tz)
+	if err != nil {
+		return analytics, err
+	}
+
+	analytics.Turnover = Turnover(analytics.Visitors.Entries, config.LotCapacity(lot).Total, r.Days())
+
+	return analytics, nil
+}
+
+// Turnover is how many cars used each space per day, zero when the capacity is unlimited
+func Turnover(entries int64, capacity int, days int) float64 {
+	if capacity <= 0 || days <= 0 {
+		return 0
+	}
+
+	return float64(entries) / float64(capacity) / float64(days)
+}
+
+// Days counts the days in the range
+func (r Range) Days() int {
+	from, _ := time.Parse(dayFormat, r.From)
+	to, _ := time.Parse(dayFormat, r.To)
+
+	return int(to.Sub(from).Hours()/24) + 1
+}
@@ -0,0 +1,192 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+)
+
+const (
+	dayFormat = "2006-01-02"
+	// MaxDays is the longest range a report may cover
+	MaxDays = 366
+)
+
+// Range is an inclusive range of days
+type Range struct {
+	From string
+	To   string
+}
+
+// Table is a report that can be written as CSV
+type Table interface {
+	Header() []string
+	Records() [][]string
+}
+
+// Revenue is the revenue report
+type Revenue []models.RevenueRow
+
+// Movements is the entries and exits report
+type Movements []models.MovementRow
+
+// Categories is the entries, exits and revenue report by vehicle category
+type Categories []models.CategoryRow
+
+// ParseRange validates a range of days written as YYYY-MM-DD
+func ParseRange(from string, to string) (Range, error) {
+	fromDay, err := time.Parse(dayFormat, from)
+	if err != nil {
+		return Range{}, utils.ErrDateRangeNotValid
+	}
+
+	toDay, err := time.Parse(dayFormat, to)
+	if err != nil {
+		return Range{}, utils.ErrDateRangeNotValid
+	}
+
+	if toDay.Before(fromDay) || toDay.Sub(fromDay) >= MaxDays*24*time.Hour {
+		return Range{}, utils.ErrDateRangeNotValid
+	}
+
+	return Range{From: from, To: to}, nil
+}
+
+// GetRevenue gets the revenue by day and payment method in a lot
+func GetRevenue(lot models.Lot, r Range) (Revenue, error) {
+	rows, err := storage.Revenue(lot.ID, r.From, r.To, config.Timezone())
+	if err != nil {
+		return nil, err
+	}
+
+	revenue := Revenue{}
+	for _, row := range rows {
+		row.Lot = lot.Name
+		revenue = append(revenue, row)
+	}
+
+	return revenue, nil
+}
+
+// GetMovements gets entries, exits and open tickets by day in a lot
+func GetMovements(lot models.Lot, r Range) (Movements, error) {
+	rows, err := storage.Movements(lot.ID, r.From, r.To, config.Timezone())
+	if err != nil {
+		return nil, err
+	}
+
+	movements := Movements{}
+	for _, row := range rows {
+		row.Lot = lot.Name
+		movements = append(movements, row)
+	}
+
+	return movements, nil
+}
+
+// GetCategories gets entries, exits and revenue by day and vehicle category in a lot
+func GetCategories(lot models.Lot, r Range) (Categories, error) {
+	rows, err := storage.Categories(lot.ID, r.From, r.To, config.Timezone())
+	if err != nil {
+		return nil, err
+	}
+
+	categories := Categories{}
+	for _, row := range rows {
+		row.Lot = lot.Name
+		categories = append(categories, row)
+	}
+
+	return categories, nil
+}
+
+// Header names the revenue columns
+func (r Revenue) Header() []string {
+	return []string{"day", "lot", "method", "payments", "amount"}
+}
+
+// Records formats the revenue rows, amounts in reais
+func (r Revenue) Records() [][]string {
+	records := [][]string{}
+	for _, row := range r {
+		records = append(records, []string{
+			row.Day,
+			row.Lot,
+			row.Method,
+			strconv.FormatInt(row.Payments, 10),
+			fmt.Sprintf("%d.%02d", row.Amount/100, row.Amount%100),
+		})
+	}
+
+	return records
+}
+
+// Header names the movement columns
+func (m Movements) Header() []string {
+	return []string{"day", "lot", "entries", "exits", "open"}
+}
+
+// Records formats the movement rows
+func (m Movements) Records() [][]string {
+	records := [][]string{}
+	for _, row := range m {
+		records = append(records, []string{
+			row.Day,
+			row.Lot,
+			strconv.FormatInt(row.Entries, 10),
+			strconv.FormatInt(row.Exits, 10),
+			strconv.FormatInt(row.Open, 10),
+		})
+	}
+
+	return records
+}
+
+// Header names the category columns
+func (c Categories) Header() []string {
+	return []string{"day", "lot", "category", "entries", "exits", "payments", "amount"}
+}
+
+// Records formats the category rows, amounts in reais
+func (c Categories) Records() [][]string {
+	records := [][]string{}
+	for _, row := range c {
+		records = append(records, []string{
+			row.Day,
+			row.Lot,
+			row.Category,
+			strconv.FormatInt(row.Entries, 10),
+			strconv.FormatInt(row.Exits, 10),
+			strconv.FormatInt(row.Payments, 10),
+			fmt.Sprintf("%d.%02d", row.Amount/100, row.Amount%100),
+		})
+	}
+
+	return records
+}
+
+// WriteCSV writes a report as CSV with a header line
+func WriteCSV(w io.Writer, table Table) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(table.Header()); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(table.Records()); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+// WriteJSON writes a report as a JSON array
+func WriteJSON(w io.Writer, table Table) error {
+	return json.NewEncoder(w).Encode(table)
+}
@@ -0,0 +1,167 @@
+package reports_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/reports"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var tx *gorm.DB
+
+var lot models.Lot
+
+func TestMain(t *testing.M) {
+	storage.ConnectTest()
+	tx = storage.StartTest()
+	lot, _ = storage.DefaultLot("Parking")
+
+	t.Run()
+
+	tx.Exec("TRUNCATE parkings CASCADE;")
+	tx.Exec("ALTER SEQUENCE parkings_id_seq RESTART WITH 1")
+	tx.Exec("ALTER SEQUENCE payments_id_seq RESTART WITH 1")
+}
+
+func TestParseRange(t *testing.T) {
+	_, err := reports.ParseRange("2020-11-10", "2020-11-01")
+	assert.Equal(t, err, utils.ErrDateRangeNotValid)
+
+	_, err = reports.ParseRange("10/11/2020", "2020-11-01")
+	assert.Equal(t, err, utils.ErrDateRangeNotValid)
+
+	_, err = reports.ParseRange("2019-01-01", "2020-11-01")
+	assert.Equal(t, err, utils.ErrDateRangeNotValid)
+
+	rng, err := reports.ParseRange("2020-11-01", "2020-11-01")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rng, reports.Range{From: "2020-11-01", To: "2020-11-01"})
+}
+
+func TestWriteCSV(t *testing.T) {
+	revenue := reports.Revenue{
+		{Day: "2020-11-01", Lot: "Parking", Method: models.PaymentPix, Payments: 2, Amount: 2550},
+	}
+
+	var buf bytes.Buffer
+	assert.Equal(t, reports.WriteCSV(&buf, revenue), nil)
+	assert.Equal(t, buf.String(), "day,lot,method,payments,amount\n2020-11-01,Parking,pix,2,25.50\n")
+}
+
+func TestGetRevenue(t *testing.T) {
+	saoPaulo, _ := time.LoadLocation("America/Sao_Paulo")
+
+	// 23:30 in São Paulo is already the next day in UTC
+	late := models.Parking{LotID: lot.ID, Plate: "REV-1234", Checkin: time.Date(2020, 11, 10, 20, 0, 0, 0, saoPaulo)}
+	tx.Create(&late)
+	tx.Create(&models.Payment{
+		LotID:     lot.ID,
+		Model:     gorm.Model{CreatedAt: time.Date(2020, 11, 10, 23, 30, 0, 0, saoPaulo)},
+		ParkingID: late.ID,
+		Paid:      true,
+		Amount:    1500,
+		Method:    models.PaymentCash,
+	})
+
+	early := models.Parking{LotID: lot.ID, Plate: "REV-1234", Checkin: time.Date(2020, 11, 11, 0, 10, 0, 0, saoPaulo)}
+	tx.Create(&early)
+	tx.Create(&models.Payment{
+		LotID:     lot.ID,
+		Model:     gorm.Model{CreatedAt: time.Date(2020, 11, 11, 0, 30, 0, 0, saoPaulo)},
+		ParkingID: early.ID,
+		Paid:      true,
+		Amount:    1000,
+		Method:    models.PaymentCash,
+	})
+
+	rng, _ := reports.ParseRange("2020-11-10", "2020-11-11")
+	revenue, err := reports.GetRevenue(lot, rng)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(revenue), 2)
+	assert.Equal(t, revenue[0].Day, "2020-11-10")
+	assert.Equal(t, revenue[0].Amount, int64(1500))
+	assert.Equal(t, revenue[1].Day, "2020-11-11")
+	assert.Equal(t, revenue[1].Amount, int64(1000))
+}
+
+func TestGetMovements(t *testing.T) {
+	saoPaulo, _ := time.LoadLocation("America/Sao_Paulo")
+
+	checkout := time.Date(2020, 10, 2, 9, 0, 0, 0, saoPaulo)
+	tx.Create(&models.Parking{LotID: lot.ID, Plate: "MOV-1234", Checkin: time.Date(2020, 10, 1, 22, 0, 0, 0, saoPaulo), Checkout: &checkout})
+	tx.Create(&models.Parking{LotID: lot.ID, Plate: "MOV-5678", Checkin: time.Date(2020, 10, 2, 8, 0, 0, 0, saoPaulo)})
+
+	rng, _ := reports.ParseRange("2020-10-01", "2020-10-03")
+	movements, err := reports.GetMovements(lot, rng)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, movements, reports.Movements{
+		{Day: "2020-10-01", Lot: "Parking", Entries: 1, Exits: 0, Open: 1},
+		{Day: "2020-10-02", Lot: "Parking", Entries: 1, Exits: 1, Open: 1},
+		{Day: "2020-10-03", Lot: "Parking", Entries: 0, Exits: 0, Open: 1},
+	})
+}
+
+func TestGetCategories(t *testing.T) {
+	saoPaulo, _ := time.LoadLocation("America/Sao_Paulo")
+
+	checkout := time.Date(2020, 8, 1, 12, 0, 0, 0, saoPaulo)
+	truck := models.Parking{LotID: lot.ID, Plate: "CAT-1234", Category: models.CategoryTruck, Checkin: time.Date(2020, 8, 1, 9, 0, 0, 0, saoPaulo), Checkout: &checkout}
+	tx.Create(&truck)
+	tx.Create(&models.Payment{
+		LotID:     lot.ID,
+		Model:     gorm.Model{CreatedAt: checkout},
+		ParkingID: truck.ID,
+		Paid:      true,
+		Amount:    4500,
+		Method:    models.PaymentPix,
+	})
+	tx.Create(&models.Parking{LotID: lot.ID, Plate: "CAT-5678", Category: models.CategoryCar, Checkin: time.Date(2020, 8, 1, 10, 0, 0, 0, saoPaulo)})
+
+	rng, _ := reports.ParseRange("2020-08-01", "2020-08-01")
+	categories, err := reports.GetCategories(lot, rng)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, categories, reports.Categories{
+		{Day: "2020-08-01", Lot: "Parking", Category: models.CategoryMotorcycle},
+		{Day: "2020-08-01", Lot: "Parking", Category: models.CategoryCar, Entries: 1},
+		{Day: "2020-08-01", Lot: "Parking", Category: models.CategoryVan},
+		{Day: "2020-08-01", Lot: "Parking", Category: models.CategoryTruck, Entries: 1, Exits: 1, Payments: 1, Amount: 4500},
+	})
+}
+
+func TestTurnover(t *testing.T) {
+	assert.Equal(t, reports.Turnover(60, 10, 3), 2.0)
+	assert.Equal(t, reports.Turnover(60, 0, 3), 0.0)
+}
+
+func TestGetAnalytics(t *testing.T) {
+	saoPaulo, _ := time.LoadLocation("America/Sao_Paulo")
+
+	stays := []time.Duration{30 * time.Minute, 60 * time.Minute, 90 * time.Minute}
+	for i, stay := range stays {
+		checkin := time.Date(2020, 9, 7, 10, 0, 0, 0, saoPaulo)
+		checkout := checkin.Add(stay)
+		tx.Create(&models.Parking{LotID: lot.ID, Plate: fmt.Sprintf("ANL-000%d", i%2), Checkin: checkin, Checkout: &checkout})
+	}
+
+	rng, _ := reports.ParseRange("2020-09-07", "2020-09-07")
+	analytics, err := reports.GetAnalytics(lot, rng)
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, analytics.Dwell.Tickets, int64(3))
+	assert.Equal(t, analytics.Dwell.Average, 60.0)
+	assert.Equal(t, analytics.Dwell.Median, 60.0)
+
+	assert.Equal(t, analytics.Visitors, models.VisitorStats{Entries: 3, Plates: 2, Repeated: 1})
+
+	// 2020-09-07 is a monday, every stay started at 10h
+	assert.Equal(t, len(analytics.Heatmap), 24)
+	assert.Equal(t, analytics.Heatmap[10], models.HeatmapCell{Weekday: 1, Hour: 10, Occupancy: 3})
+	assert.Equal(t, analytics.Heatmap[11], models.HeatmapCell{Weekday: 1, Hour: 11, Occupancy: 1})
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// capacityLock serializes the capacity checks of a lot across server replicas, it is locked with the lot id
+const capacityLock = 4000
+
+// CreateBooking holds a space of a lot for the requested time window, an empty category is the one the plate format is issued to.
+// A window the car may use within the no-show grace also counts the cars parked right now
+func CreateBooking(lotID uint, request models.BookingRequest, capacity models.Capacity, grace time.Duration) (uint, error) {
+	booking := models.Booking{
+		LotID:    lotID,
+		Plate:    request.Plate,
+		Category: request.Category,
+		StartsAt: request.StartsAt,
+		EndsAt:   request.EndsAt,
+		Prepaid:  request.Prepaid,
+		Status:   models.BookingBooked,
+	}
+	if booking.Category == "" {
+		booking.Category = models.PlateCategory(request.Plate)
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if capacity.Limited() {
+			if err := lockCapacity(tx, lotID); err != nil {
+				return err
+			}
+
+			soon := request.StartsAt.Before(now.Add(grace))
+			if capacity.Total > 0 {
+				held, err := heldSpaces(tx, lotID, request, "", soon)
+				if err != nil {
+					return err
+				}
+				if held >= capacity.Total {
+					return utils.ErrLotFull
+				}
+			}
+
+			if spaces := capacity.Of(booking.Category); spaces > 0 {
+				held, err := heldSpaces(tx, lotID, request, booking.Category, soon)
+				if err != nil {
+					return err
+				}
+				if held >= spaces {
+					return utils.ErrLotFull
+				}
+			}
+		}
+
+		return tx.Create(&booking).Error
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrLotFull) {
+			return 0, err
+		}
+		logrus.Warn(err.Error())
+		return 0, utils.ErrInternalServer
+	}
+
+	return booking.ID, nil
+}
+
+// heldSpaces counts the bookings overlapping the window of a request, plus the open tickets when the window starts soon
+func heldSpaces(tx *gorm.DB, lotID uint, request models.BookingRequest, category string, soon bool) (int, error) {
+	held, err := heldBookings(tx, lotID, request.StartsAt, request.EndsAt, category)
+	if err != nil || !soon {
+		return held, err
+	}
+
+	open, err := openTickets(tx, lotID, category)
+
+	return held + open, err
+}
+
+// heldBookings counts the bookings of a lot overlapping a time window, of a category or of all when it is empty
+func heldBookings(tx *gorm.DB, lotID uint, startsAt time.Time, endsAt time.Time, category string) (int, error) {
+	query := tx.Model(&models.Booking{}).Scopes(inLot(lotID)).
+		Where("status = ? AND starts_at < ? AND ends_at > ?", models.BookingBooked, endsAt, startsAt)
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var held int64
+	err := query.Count(&held).Error
+
+	return int(held), err
+}
+
+// CancelBooking releases a booked space of a lot
+func CancelBooking(lotID uint, id uint) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var booking models.Booking
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(inLot(lotID)).First(&booking, id).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrNotFound
+			}
+			return err
+		}
+
+		if booking.Status != models.BookingBooked {
+			return utils.ErrBookingClosed
+		}
+
+		return tx.Model(&booking).Update("status", models.BookingCancelled).Error
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) || errors.Is(err, utils.ErrBookingClosed) {
+			return err
+		}
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
+
+// Bookings lists the bookings of a lot, optionally filtered by plate and status
+func Bookings(lotID uint, plate string, status string) ([]models.Booking, error) {
+	query := db.Scopes(inLot(lotID)).Order("starts_at")
+	if plate != "" {
+		query = query.Where("plate = ?", plate)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var bookings []models.Booking
+	if err := query.Find(&bookings).Error; err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return bookings, nil
+}
+
+// ExpireBookings marks as expired every booking, of every lot, that started before the deadline and was never used
+func ExpireBookings(deadline time.Time) (int64, error) {
+	tx := db.Model(&models.Booking{}).
+		Where("status = ? AND starts_at < ?", models.BookingBooked, deadline).
+		Update("status", models.BookingExpired)
+	if tx.Error != nil {
+		logrus.Warn(tx.Error.Error())
+		return 0, utils.ErrInternalServer
+	}
+
+	return tx.RowsAffected, nil
+}
+
+// findBooking gets the booking in a lot a plate arriving now may use, locking it
+func findBooking(tx *gorm.DB, lotID uint, plate string, now time.Time, grace time.Duration) (*models.Booking, error) {
+	var booking models.Booking
+	res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(inLot(lotID)).
+		Where("plate = ? AND status = ? AND starts_at <= ? AND ends_at > ?", plate, models.BookingBooked, now.Add(grace), now).
+		Order("starts_at").
+		Limit(1).
+		Find(&booking)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &booking, nil
+}
+
+// openTickets counts the cars parked in a lot, of a category or of all when it is empty
+func openTickets(tx *gorm.DB, lotID uint, category string) (int, error) {
+	parkings := tx.Model(&models.Parking{}).Scopes(inLot(lotID)).Where("checkout IS NULL")
+	if category != "" {
+		parkings = parkings.Where("category = ?", category)
+	}
+
+	var open int64
+	err := parkings.Count(&open).Error
+
+	return int(open), err
+}
+
+// occupancy counts the open tickets of a lot plus its bookings holding a space right now, of a category or of all when it is empty
+func occupancy(tx *gorm.DB, lotID uint, now time.Time, category string) (int, error) {
+	open, err := openTickets(tx, lotID, category)
+	if err != nil {
+		return 0, err
+	}
+
+	var held int64
+	bookings := tx.Model(&models.Booking{}).Scopes(inLot(lotID)).Where("status = ? AND starts_at <= ? AND ends_at > ?", models.BookingBooked, now, now)
+	if category != "" {
+		bookings = bookings.Where("category = ?", category)
+	}
+	if err := bookings.Count(&held).Error; err != nil {
+		return 0, err
+	}
+
+	return open + int(held), nil
+}
+
+// checkCapacity refuses a new ticket when the lot, or the spaces of its category, are full
+func checkCapacity(tx *gorm.DB, lotID uint, now time.Time, category string, capacity models.Capacity) error {
+	if capacity.Total > 0 {
+		occupied, err := occupancy(tx, lotID, now, "")
+		if err != nil {
+			return err
+		}
+		if occupied >= capacity.Total {
+			return utils.ErrLotFull
+		}
+	}
+
+	if spaces := capacity.Of(category); spaces > 0 {
+		occupied, err := occupancy(tx, lotID, now, category)
+		if err != nil {
+			return err
+		}
+		if occupied >= spaces {
+			return utils.ErrLotFull
+		}
+	}
+
+	return nil
+}
+
+func lockCapacity(tx *gorm.DB, lotID uint) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", capacityLock, lotID).Error
+}
@@ -1,16 +1,20 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
 	"br.com.mlabs/models"
+	"br.com.mlabs/plates"
 	"br.com.mlabs/utils"
+	"github.com/jackc/pgconn"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var db *gorm.DB
@@ -82,19 +86,84 @@ func StartTest() *gorm.DB {
 	return db
 }
 
+// migrate brings the schema up to date, the server does not start on a schema it could not migrate
 func migrate() {
-	db.AutoMigrate(&models.Parking{})
-	db.AutoMigrate(&models.Payment{})
+	if err := dedupPayments(); err != nil {
+		logrus.Panic(err.Error())
+	}
+
+	for _, model := range []interface{}{
+		&models.Parking{},
+		&models.Payment{},
+		&models.Booking{},
+		&models.Receipt{},
+		&models.ReceiptSequence{},
+		&models.GateEvent{},
+		&models.IdempotencyKey{},
+		&models.Review{},
+		&models.Company{},
+		&models.Lot{},
+		&models.LotCategory{},
+		&models.User{},
+	} {
+		if err := db.AutoMigrate(model); err != nil {
+			logrus.Panic(err.Error())
+		}
+	}
+
+	// Receipt numbers became unique per lot, gate event ids per device of a lot
+	for _, index := range []string{"idx_receipts_number", "idx_gate_events_event_id", "idx_lot_event"} {
+		if err := db.Exec("DROP INDEX IF EXISTS " + index).Error; err != nil {
+			logrus.Panic(err.Error())
+		}
+	}
 }
 
-// ParkingReservation creates a new record on the database
-func ParkingReservation(request models.ParkingRequest) (uint, error) {
-	parking := models.Parking{
-		Plate:   request.Plate,
-		Checkin: time.Now(),
+// dedupPayments removes the extra payments concurrent requests stored for a parking before payments were unique,
+// keeping the first. Extra payments with a receipt are not removed and stop the migration, they must be reviewed by hand
+func dedupPayments() error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Payment{}) || migrator.HasIndex(&models.Payment{}, "ParkingID") {
+		return nil
+	}
+
+	query := "DELETE FROM payments p USING payments first WHERE p.parking_id = first.parking_id AND p.id > first.id"
+	if migrator.HasTable(&models.Receipt{}) {
+		query += " AND p.id NOT IN (SELECT payment_id FROM receipts)"
 	}
-	err := db.Create(&parking).Error
+	res := db.Exec(query)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		logrus.Warnf("Removed %d duplicate payments", res.RowsAffected)
+	}
+
+	var duplicates int64
+	err := db.Raw("SELECT COUNT(*) FROM (SELECT parking_id FROM payments GROUP BY parking_id HAVING COUNT(*) > 1) d").Scan(&duplicates).Error
 	if err != nil {
+		return err
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("%d parkings have more than one receipted payment, keep one of each before payments can be unique", duplicates)
+	}
+
+	return nil
+}
+
+// ParkingReservation creates a new record on the database, converting the plate's booking if there is one
+func ParkingReservation(lotID uint, request models.ParkingRequest, capacity models.Capacity, grace time.Duration) (uint, error) {
+	var parking models.Parking
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		parking, err = checkin(tx, lotID, request.Plate, request.Category, request.Image, time.Now(), capacity, grace)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrLotFull) {
+			return 0, err
+		}
 		logrus.Warn(err.Error())
 		return 0, utils.ErrInternalServer
 	}
@@ -102,9 +171,99 @@ func ParkingReservation(request models.ParkingRequest) (uint, error) {
 	return parking.ID, nil
 }
 
-// ParkingHistory gets all reservation entries
-func ParkingHistory(request models.ParkingRequest) ([]models.ParkingPayments, error) {
-	rows, err := db.Model(&models.Parking{}).Joins("LEFT JOIN payments ON parkings.id = payments.parking_id").Select("parkings.*, payments.paid").Where("parkings.plate = ?", request.Plate).Rows()
+// checkin creates the parking record at the given time, capacity is only checked when there is no booking to convert.
+// An empty category is the booking's, or the one the plate format is issued to
+func checkin(tx *gorm.DB, lotID uint, plate string, category string, image string, at time.Time, capacity models.Capacity, grace time.Duration) (models.Parking, error) {
+	parking := models.Parking{
+		LotID:    lotID,
+		Plate:    plate,
+		Checkin:  at,
+		Category: category,
+		Image:    image,
+	}
+	if format, ok := plates.Lookup(plate); ok {
+		parking.PlateFormat = format.ID
+		parking.Country = format.Country
+	}
+
+	if capacity.Limited() {
+		if err := lockCapacity(tx, lotID); err != nil {
+			return parking, err
+		}
+	}
+
+	booking, err := findBooking(tx, lotID, plate, at, grace)
+	if err != nil {
+		return parking, err
+	}
+
+	if parking.Category == "" && booking != nil {
+		parking.Category = booking.Category
+	}
+	if parking.Category == "" {
+		parking.Category = models.PlateCategory(plate)
+	}
+
+	if booking != nil {
+		parking.BookingID = &booking.ID
+		parking.Prepaid = booking.Prepaid
+	} else if err := checkCapacity(tx, lotID, at, parking.Category, capacity); err != nil {
+		return parking, err
+	}
+
+	if err := tx.Create(&parking).Error; err != nil {
+		return parking, err
+	}
+
+	if booking == nil {
+		return parking, nil
+	}
+
+	err = tx.Model(booking).Updates(map[string]interface{}{
+		"status":     models.BookingConverted,
+		"parking_id": parking.ID,
+	}).Error
+
+	return parking, err
+}
+
+// GetParking gets a parking record of a lot by id
+func GetParking(lotID uint, id uint) (models.Parking, error) {
+	var parking models.Parking
+	err := db.Scopes(inLot(lotID)).First(&parking, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return parking, utils.ErrNotFound
+		}
+		logrus.Warn(err.Error())
+		return parking, utils.ErrInternalServer
+	}
+
+	return parking, nil
+}
+
+// OpenParking gets the only parking of a plate in a lot that has not checked out, under either form of the plate
+func OpenParking(lotID uint, plate string) (models.Parking, error) {
+	var parkings []models.Parking
+	err := db.Scopes(inLot(lotID)).Where("plate IN ? AND checkout IS NULL", models.PlateForms(plate)).Limit(2).Find(&parkings).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return models.Parking{}, utils.ErrInternalServer
+	}
+
+	switch len(parkings) {
+	case 0:
+		return models.Parking{}, utils.ErrNotFound
+	case 1:
+		return parkings[0], nil
+	}
+
+	return models.Parking{}, utils.ErrSeveralOpen
+}
+
+// ParkingHistory gets all reservation entries in the lots, under either form of the plate
+func ParkingHistory(lotIDs []uint, request models.ParkingRequest) ([]models.ParkingPayments, error) {
+	rows, err := db.Model(&models.Parking{}).Joins("LEFT JOIN payments ON parkings.id = payments.parking_id").Select("parkings.*, payments.paid").Where("parkings.plate IN ? AND parkings.lot_id IN ?", models.PlateForms(request.Plate), lotIDs).Order("parkings.id").Rows()
 	defer rows.Close()
 	if err != nil {
 		logrus.Warn(err.Error())
@@ -126,34 +285,86 @@ func ParkingHistory(request models.ParkingRequest) ([]models.ParkingPayments, er
 	return parkingWithPayments, nil
 }
 
-// Pay sets the payment in the database
-func Pay(id uint) error {
-	paid, err := IsPaid(id)
+// Pay sets the payment in the database and issues its receipt, the parking row is locked so concurrent payments wait and see the first one
+func Pay(lotID uint, id uint, pay models.Payment) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockParking(tx, lotID, id); err != nil {
+			return err
+		}
+
+		var payments int64
+		if err := tx.Model(&models.Payment{}).Where("parking_id = ?", id).Count(&payments).Error; err != nil {
+			return err
+		}
+		if payments > 0 {
+			return utils.ErrAlreadyPaid
+		}
 
+		pay.LotID = lotID
+		pay.ParkingID = id
+		return createPayment(tx, &pay)
+	})
 	if err != nil {
-		return err
+		if errors.Is(err, utils.ErrNotFound) || errors.Is(err, utils.ErrAlreadyPaid) {
+			return err
+		}
+		if isUniqueViolation(err) {
+			return utils.ErrAlreadyPaid
+		}
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
 	}
-	if paid {
-		return utils.ErrAlreadyPaid
+
+	return nil
+}
+
+// GetReceipt gets the receipt of a parking space in a lot, with its payment and parking
+func GetReceipt(lotID uint, parkingID uint) (models.Receipt, error) {
+	var receipt models.Receipt
+	err := db.Preload("Payment.Parking").
+		Joins("JOIN payments ON payments.id = receipts.payment_id").
+		Where("payments.parking_id = ? AND receipts.lot_id = ?", parkingID, lotID).
+		First(&receipt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return receipt, utils.ErrNotPaid
+		}
+		logrus.Warn(err.Error())
+		return receipt, utils.ErrInternalServer
 	}
 
-	pay := models.Payment{
-		Paid:      true,
-		ParkingID: id,
+	return receipt, nil
+}
+
+// createPayment stores a payment and issues its receipt in the payment's lot
+func createPayment(tx *gorm.DB, pay *models.Payment) error {
+	pay.Paid = true
+	if err := tx.Create(pay).Error; err != nil {
+		return err
 	}
-	err = db.Create(&pay).Error
+
+	return issueReceipt(tx, pay.LotID, pay.ID)
+}
+
+// issueReceipt takes the next receipt number of the lot, the sequence row stays locked until the transaction ends so numbers have no gaps
+func issueReceipt(tx *gorm.DB, lotID uint, paymentID uint) error {
+	var number uint
+	err := tx.Raw("UPDATE receipt_sequences SET last = last + 1 WHERE id = ? RETURNING last", lotID).Scan(&number).Error
 	if err != nil {
-		logrus.Warn(err.Error())
-		return utils.ErrInternalServer
+		return err
 	}
 
-	return nil
+	return tx.Create(&models.Receipt{
+		LotID:     lotID,
+		Number:    number,
+		PaymentID: paymentID,
+	}).Error
 }
 
-// IsPaid returns true if a parking space has been paid
-func IsPaid(id uint) (bool, error) {
+// IsPaid returns true if a parking space of a lot has been paid
+func IsPaid(lotID uint, id uint) (bool, error) {
 	var res models.ParkingPayments
-	tx := db.Joins("LEFT JOIN payments ON payments.parking_id = parkings.id").Select("payments.paid").Where("parkings.id = ?", id).First(&models.Parking{})
+	tx := db.Joins("LEFT JOIN payments ON payments.parking_id = parkings.id").Select("payments.paid").Where("parkings.id = ? AND parkings.lot_id = ?", id, lotID).First(&models.Parking{})
 	if tx.Error != nil {
 		if strings.Contains(tx.Error.Error(), "record not found") {
 			return false, utils.ErrNotFound
@@ -166,18 +377,23 @@ func IsPaid(id uint) (bool, error) {
 	return res.Paid, nil
 }
 
-// Checkout checks out a parking space
-func Checkout(id uint) error {
-	ok, err := HaveCheckedOut(id)
-	if err != nil {
-		return err
-	}
-	if ok {
-		return utils.ErrAlreadyCheckedOut
-	}
+// Checkout checks out a parking space of a lot, the parking row is locked so only one checkout happens
+func Checkout(lotID uint, id uint) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		parking, err := lockParking(tx, lotID, id)
+		if err != nil {
+			return err
+		}
+		if parking.Checkout != nil {
+			return utils.ErrAlreadyCheckedOut
+		}
 
-	err = db.Model(&models.Parking{}).Where("id = ?", id).Update("checkout", time.Now()).Error
+		return tx.Model(&parking).Update("checkout", time.Now()).Error
+	})
 	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) || errors.Is(err, utils.ErrAlreadyCheckedOut) {
+			return err
+		}
 		logrus.Warn(err.Error())
 		return utils.ErrInternalServer
 	}
@@ -185,19 +401,22 @@ func Checkout(id uint) error {
 	return nil
 }
 
-// HaveCheckedOut returns true if a parking space has been checked out
-func HaveCheckedOut(id uint) (bool, error) {
-	tx := db.Where("id = ?", id).Select("checkout").First(&models.Parking{})
-	if tx.Error != nil {
-		logrus.Warn(tx.Error.Error())
-		if strings.Contains(tx.Error.Error(), "record not found") {
-			return false, utils.ErrNotFound
+// lockParking gets a parking record of a lot with SELECT ... FOR UPDATE, the lock holds until the transaction ends
+func lockParking(tx *gorm.DB, lotID uint, id uint) (models.Parking, error) {
+	var parking models.Parking
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(inLot(lotID)).First(&parking, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return parking, utils.ErrNotFound
 		}
-		return false, utils.ErrInternalServer
+		return parking, err
 	}
 
-	var parking models.Parking
-	tx.Scan(&parking)
+	return parking, nil
+}
 
-	return parking.Checkout != nil, nil
+// isUniqueViolation tells if an insert hit a unique constraint
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
 }
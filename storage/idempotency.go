@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClaimIdempotencyKey reserves a key for a request for the lease, it returns true when the caller must run the request.
+// Otherwise the stored key is returned, expired keys and claims whose lease ran out are claimed again.
+func ClaimIdempotencyKey(key string, fingerprint string, lease time.Duration) (models.IdempotencyKey, bool, error) {
+	now := time.Now()
+	record := models.IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(lease),
+	}
+
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if res.Error != nil {
+		logrus.Warn(res.Error.Error())
+		return record, false, utils.ErrInternalServer
+	}
+	if res.RowsAffected == 1 {
+		return record, true, nil
+	}
+
+	res = db.Model(&models.IdempotencyKey{}).
+		Where("key = ? AND expires_at < ?", key, now).
+		Updates(map[string]interface{}{
+			"fingerprint":  fingerprint,
+			"status":       0,
+			"content_type": "",
+			"body":         nil,
+			"created_at":   now,
+			"expires_at":   record.ExpiresAt,
+		})
+	if res.Error != nil {
+		logrus.Warn(res.Error.Error())
+		return record, false, utils.ErrInternalServer
+	}
+	if res.RowsAffected == 1 {
+		return record, true, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := db.Where("key = ?", key).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released between the insert and now, let the client retry
+			return existing, false, utils.ErrIdempotencyInProgress
+		}
+		logrus.Warn(err.Error())
+		return existing, false, utils.ErrInternalServer
+	}
+
+	return existing, false, nil
+}
+
+// SaveIdempotentResponse stores the response of a claimed key, replayed until the ttl
+func SaveIdempotentResponse(key string, status int, contentType string, body []byte, ttl time.Duration) error {
+	err := db.Model(&models.IdempotencyKey{}).Where("key = ?", key).Updates(map[string]interface{}{
+		"status":       status,
+		"content_type": contentType,
+		"body":         body,
+		"expires_at":   time.Now().Add(ttl),
+	}).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a claimed key so the request can be retried
+func ReleaseIdempotencyKey(key string) error {
+	err := db.Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes the expired keys
+func PurgeIdempotencyKeys() (int64, error) {
+	tx := db.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{})
+	if tx.Error != nil {
+		logrus.Warn(tx.Error.Error())
+		return 0, utils.ErrInternalServer
+	}
+
+	return tx.RowsAffected, nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// ExpiredImages lists the image keys only used by tickets and resolved reviews captured before the cutoff,
+// images are content addressed so a key still used by a newer row or a pending review is kept
+func ExpiredImages(cutoff time.Time) ([]string, error) {
+	var keys []string
+	err := db.Raw(`
+		SELECT image FROM parkings WHERE image <> '' AND checkin < ?
+		UNION
+		SELECT image FROM reviews WHERE image <> '' AND captured_at < ? AND status <> ?
+		EXCEPT (
+			SELECT image FROM parkings WHERE checkin >= ?
+			UNION
+			SELECT image FROM reviews WHERE captured_at >= ? OR status = ?
+		)`, cutoff, cutoff, models.ReviewPending, cutoff, cutoff, models.ReviewPending).Scan(&keys).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return keys, nil
+}
+
+// ImageUsed tells whether a ticket or a review still points at the image
+func ImageUsed(key string) (bool, error) {
+	var used bool
+	err := db.Raw(`SELECT EXISTS (SELECT 1 FROM parkings WHERE image = ?) OR EXISTS (SELECT 1 FROM reviews WHERE image = ?)`, key, key).Scan(&used).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return false, utils.ErrInternalServer
+	}
+
+	return used, nil
+}
+
+// ForgetImages unlinks purged images from their tickets and reviews
+func ForgetImages(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := db.Model(&models.Parking{}).Where("image IN ?", keys).Update("image", "").Error
+	if err == nil {
+		err = db.Model(&models.Review{}).Where("image IN ?", keys).Update("image", "").Error
+	}
+	if err != nil {
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChargeFunc prices a payment made at the given time
+type ChargeFunc func(parking models.Parking, at time.Time, method string) (models.Payment, error)
+
+// ImportEvents applies a batch of gate events of a lot in one transaction, filling in the results.
+// Events whose result is already set are skipped, an event that cannot be applied is rolled back alone
+// and reported as failed, any other error rolls back the whole batch.
+func ImportEvents(lotID uint, deviceID string, events []models.ImportEvent, results []models.ImportResult, grace time.Duration, charge ChargeFunc) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, event := range events {
+			if results[i].Status != "" {
+				continue
+			}
+
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return importEvent(tx, lotID, deviceID, event, &results[i], grace, charge)
+			})
+			if err == nil {
+				continue
+			}
+			if !isEventError(err) {
+				return err
+			}
+
+			results[i].Status = models.ImportFailed
+			results[i].TicketID = 0
+			results[i].Error = err.Error()
+		}
+
+		return nil
+	})
+	if err != nil {
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
+
+func importEvent(tx *gorm.DB, lotID uint, deviceID string, event models.ImportEvent, result *models.ImportResult, grace time.Duration, charge ChargeFunc) error {
+	record := models.GateEvent{
+		LotID:    lotID,
+		EventID:  event.EventID,
+		DeviceID: deviceID,
+		Type:     event.Type,
+		Time:     event.Time,
+	}
+
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		var existing models.GateEvent
+		if err := tx.Scopes(inLot(lotID)).Where("device_id = ? AND event_id = ?", deviceID, event.EventID).First(&existing).Error; err != nil {
+			return err
+		}
+
+		result.Status = models.ImportDuplicate
+		result.TicketID = existing.ParkingID
+		return nil
+	}
+
+	id := event.ParkingID
+	if event.TicketEventID != "" {
+		var ticketEvent models.GateEvent
+		err := tx.Scopes(inLot(lotID)).Where("device_id = ? AND event_id = ? AND type = ?", deviceID, event.TicketEventID, models.EventCheckin).First(&ticketEvent).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrNotFound
+			}
+			return err
+		}
+		id = ticketEvent.ParkingID
+	}
+
+	switch event.Type {
+	case models.EventCheckin:
+		parking, err := checkin(tx, lotID, event.Plate, "", "", event.Time, models.Capacity{}, grace)
+		if err != nil {
+			return err
+		}
+		id = parking.ID
+	case models.EventPayment:
+		if err := importPayment(tx, lotID, id, event, charge); err != nil {
+			return err
+		}
+	case models.EventCheckout:
+		if err := importCheckout(tx, lotID, id, event.Time); err != nil {
+			return err
+		}
+	}
+
+	result.Status = models.ImportApplied
+	result.TicketID = id
+
+	return tx.Model(&record).Update("parking_id", id).Error
+}
+
+func importPayment(tx *gorm.DB, lotID uint, id uint, event models.ImportEvent, charge ChargeFunc) error {
+	parking, err := lockParking(tx, lotID, id)
+	if err != nil {
+		return err
+	}
+
+	var payments int64
+	if err := tx.Model(&models.Payment{}).Where("parking_id = ?", id).Count(&payments).Error; err != nil {
+		return err
+	}
+	if payments > 0 {
+		return utils.ErrAlreadyPaid
+	}
+
+	pay, err := charge(parking, event.Time, event.Method)
+	if err != nil {
+		return err
+	}
+	pay.LotID = lotID
+	pay.ParkingID = id
+	pay.CreatedAt = event.Time
+
+	return createPayment(tx, &pay)
+}
+
+func importCheckout(tx *gorm.DB, lotID uint, id uint, at time.Time) error {
+	parking, err := lockParking(tx, lotID, id)
+	if err != nil {
+		return err
+	}
+	if parking.Checkout != nil {
+		return utils.ErrAlreadyCheckedOut
+	}
+
+	return tx.Model(&parking).Update("checkout", at).Error
+}
+
+// isEventError tells apart the errors caused by the event itself
+func isEventError(err error) bool {
+	return errors.Is(err, utils.ErrNotFound) ||
+		errors.Is(err, utils.ErrAlreadyPaid) ||
+		errors.Is(err, utils.ErrAlreadyCheckedOut)
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"errors"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tenantTables are the tables whose rows belong to a lot
+var tenantTables = []string{"parkings", "payments", "receipts", "bookings", "reviews", "gate_events"}
+
+// inLot scopes a query to the rows of a lot
+func inLot(lotID uint) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("lot_id = ?", lotID)
+	}
+}
+
+// DefaultLot gets the lot of the deployment by name, creating it the first time.
+// Rows stored before there were lots are given to it
+func DefaultLot(name string) (models.Lot, error) {
+	var lot models.Lot
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(models.Lot{Name: name}).FirstOrCreate(&lot).Error; err != nil {
+			return err
+		}
+		if err := createReceiptSequence(tx, lot.ID); err != nil {
+			return err
+		}
+
+		for _, table := range tenantTables {
+			if err := tx.Exec("UPDATE "+table+" SET lot_id = ? WHERE lot_id IS NULL", lot.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Preload("Categories").Preload("Company").First(&lot, lot.ID).Error
+	})
+	if err != nil {
+		logrus.Warn(err.Error())
+		return lot, utils.ErrInternalServer
+	}
+
+	return lot, nil
+}
+
+// CreateLot stores a lot with its categories and starts its receipt numbers
+func CreateLot(lot *models.Lot) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(lot).Error; err != nil {
+			return err
+		}
+
+		return createReceiptSequence(tx, lot.ID)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return utils.ErrLotExists
+		}
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
+
+// GetLot gets a lot by id with its categories and company
+func GetLot(id uint) (models.Lot, error) {
+	var lot models.Lot
+	err := db.Preload("Categories").Preload("Company").First(&lot, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return lot, utils.ErrNotFound
+		}
+		logrus.Warn(err.Error())
+		return lot, utils.ErrInternalServer
+	}
+
+	return lot, nil
+}
+
+// LotByName gets a lot by name with its categories and company
+func LotByName(name string) (models.Lot, error) {
+	var lot models.Lot
+	err := db.Preload("Categories").Preload("Company").Where("name = ?", name).First(&lot).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return lot, utils.ErrNotFound
+		}
+		logrus.Warn(err.Error())
+		return lot, utils.ErrInternalServer
+	}
+
+	return lot, nil
+}
+
+// Lots lists the lots of a company by name, every lot when companyID is nil
+func Lots(companyID *uint) ([]models.Lot, error) {
+	query := db.Preload("Company").Order("name")
+	if companyID != nil {
+		query = query.Where("company_id = ?", *companyID)
+	}
+
+	var lots []models.Lot
+	if err := query.Find(&lots).Error; err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return lots, nil
+}
+
+// Company gets a company by name, creating it the first time
+func Company(name string) (models.Company, error) {
+	var company models.Company
+	if err := db.Where(models.Company{Name: name}).FirstOrCreate(&company).Error; err != nil {
+		logrus.Warn(err.Error())
+		return company, utils.ErrInternalServer
+	}
+
+	return company, nil
+}
+
+// CreateUser stores a user of a lot
+func CreateUser(user *models.User) error {
+	if err := db.Create(user).Error; err != nil {
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
+
+// UserByKey gets the user with the hash of an API key, with its lot
+func UserByKey(hash string) (models.User, error) {
+	var user models.User
+	err := db.Preload("Lot.Categories").Preload("Lot.Company").Where("key_hash = ?", hash).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, utils.ErrUnauthorized
+		}
+		logrus.Warn(err.Error())
+		return user, utils.ErrInternalServer
+	}
+
+	return user, nil
+}
+
+// createReceiptSequence starts the receipt numbers of a lot, the sequence row shares the lot's id
+func createReceiptSequence(tx *gorm.DB, lotID uint) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ReceiptSequence{ID: lotID}).Error
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"strings"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// Revenue sums the payments made in a lot from day to day, by day and method, days start at midnight in tz
+func Revenue(lotID uint, from string, to string, tz string) ([]models.RevenueRow, error) {
+	var rows []models.RevenueRow
+	err := db.Raw(`
+		SELECT to_char(created_at AT TIME ZONE ?, 'YYYY-MM-DD') AS day,
+			method,
+			COUNT(*) AS payments,
+			COALESCE(SUM(amount), 0) AS amount
+		FROM payments
+		WHERE deleted_at IS NULL AND paid AND lot_id = ?
+			AND created_at >= (?::timestamp AT TIME ZONE ?)
+			AND created_at < ((?::timestamp + interval '1 day') AT TIME ZONE ?)
+		GROUP BY 1, 2
+		ORDER BY 1, 2`, tz, lotID, from, tz, to, tz).Scan(&rows).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return rows, nil
+}
+
+// Movements counts the entries, exits and tickets still open in a lot at the end of each day from day to day
+func Movements(lotID uint, from string, to string, tz string) ([]models.MovementRow, error) {
+	var rows []models.MovementRow
+	err := db.Raw(`
+		WITH days AS (
+			SELECT day, day AT TIME ZONE ? AS starts, (day + interval '1 day') AT TIME ZONE ? AS ends
+			FROM generate_series(?::timestamp, ?::timestamp, interval '1 day') AS day
+		)
+		SELECT to_char(days.day, 'YYYY-MM-DD') AS day,
+			(SELECT COUNT(*) FROM parkings WHERE deleted_at IS NULL AND lot_id = ? AND checkin >= days.starts AND checkin < days.ends) AS entries,
+			(SELECT COUNT(*) FROM parkings WHERE deleted_at IS NULL AND lot_id = ? AND checkout >= days.starts AND checkout < days.ends) AS exits,
+			(SELECT COUNT(*) FROM parkings WHERE deleted_at IS NULL AND lot_id = ? AND checkin < days.ends AND (checkout IS NULL OR checkout >= days.ends)) AS open
+		FROM days
+		ORDER BY days.day`, tz, tz, from, to, lotID, lotID, lotID).Scan(&rows).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return rows, nil
+}
+
+// Categories counts entries and exits and sums the payments of each vehicle category in a lot from day to day
+func Categories(lotID uint, from string, to string, tz string) ([]models.CategoryRow, error) {
+	var rows []models.CategoryRow
+	err := db.Raw(`
+		WITH days AS (
+			SELECT day, day AT TIME ZONE ? AS starts, (day + interval '1 day') AT TIME ZONE ? AS ends
+			FROM generate_series(?::timestamp, ?::timestamp, interval '1 day') AS day
+		), categories AS (
+			SELECT category, ordinality FROM unnest(string_to_array(?, ',')) WITH ORDINALITY AS c(category, ordinality)
+		)
+		SELECT to_char(days.day, 'YYYY-MM-DD') AS day,
+			categories.category,
+			(SELECT COUNT(*) FROM parkings WHERE deleted_at IS NULL AND lot_id = ? AND category = categories.category
+				AND checkin >= days.starts AND checkin < days.ends) AS entries,
+			(SELECT COUNT(*) FROM parkings WHERE deleted_at IS NULL AND lot_id = ? AND category = categories.category
+				AND checkout >= days.starts AND checkout < days.ends) AS exits,
+			(SELECT COUNT(*) FROM payments JOIN parkings ON parkings.id = payments.parking_id
+				WHERE payments.deleted_at IS NULL AND payments.paid AND payments.lot_id = ? AND parkings.category = categories.category
+				AND payments.created_at >= days.starts AND payments.created_at < days.ends) AS payments,
+			(SELECT COALESCE(SUM(payments.amount), 0) FROM payments JOIN parkings ON parkings.id = payments.parking_id
+				WHERE payments.deleted_at IS NULL AND payments.paid AND payments.lot_id = ? AND parkings.category = categories.category
+				AND payments.created_at >= days.starts AND payments.created_at < days.ends) AS amount
+		FROM days CROSS JOIN categories
+		ORDER BY days.day, categories.ordinality`, tz, tz, from, to, strings.Join(models.Categories, ","), lotID, lotID, lotID, lotID).Scan(&rows).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return rows, nil
+}
+
+// DwellTime gets the average, median and 95th percentile of the stays in a lot that started from day to day
+func DwellTime(lotID uint, from string, to string, tz string) (models.DwellStats, error) {
+	var stats models.DwellStats
+	err := db.Raw(`
+		WITH stays AS (
+			SELECT (EXTRACT(EPOCH FROM checkout - checkin) / 60)::float8 AS minutes
+			FROM parkings
+			WHERE deleted_at IS NULL AND lot_id = ? AND checkout IS NOT NULL
+				AND checkin >= (?::timestamp AT TIME ZONE ?)
+				AND checkin < ((?::timestamp + interval '1 day') AT TIME ZONE ?)
+		)
+		SELECT COUNT(*) AS tickets,
+			COALESCE(AVG(minutes), 0)::float8 AS average,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY minutes), 0) AS median,
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY minutes), 0) AS p95
+		FROM stays`, lotID, from, tz, to, tz).Scan(&stats).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return stats, utils.ErrInternalServer
+	}
+
+	return stats, nil
+}
+
+// OccupancyHeatmap averages, for each hour of the week, how many cars were parked in a lot from day to day.
+// Only the tickets overlapping the range are matched against the hours, found by the lot and checkin index
+func OccupancyHeatmap(lotID uint, from string, to string, tz string) ([]models.HeatmapCell, error) {
+	var cells []models.HeatmapCell
+	err := db.Raw(`
+		WITH slots AS (
+			SELECT slot AT TIME ZONE ? AS starts,
+				(slot + interval '1 hour') AT TIME ZONE ? AS ends,
+				EXTRACT(ISODOW FROM slot)::int AS weekday,
+				EXTRACT(HOUR FROM slot)::int AS hour
+			FROM generate_series(?::timestamp, ?::timestamp + interval '23 hours', interval '1 hour') AS slot
+		), stays AS (
+			SELECT id, checkin, checkout
+			FROM parkings
+			WHERE deleted_at IS NULL AND lot_id = ?
+				AND checkin < ((?::timestamp + interval '1 day') AT TIME ZONE ?)
+				AND (checkout IS NULL OR checkout > (?::timestamp AT TIME ZONE ?))
+		), counts AS (
+			SELECT slots.weekday, slots.hour, COUNT(stays.id) AS cars
+			FROM slots
+			LEFT JOIN stays ON stays.checkin < slots.ends
+				AND (stays.checkout IS NULL OR stays.checkout > slots.starts)
+			WHERE slots.starts < now()
+			GROUP BY slots.starts, slots.weekday, slots.hour
+		)
+		SELECT weekday, hour, AVG(cars)::float8 AS occupancy
+		FROM counts
+		GROUP BY weekday, hour
+		ORDER BY weekday, hour`, tz, tz, from, to, lotID, to, tz, from, tz).Scan(&cells).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return cells, nil
+}
+
+// Visitors counts the entries in a lot from day to day and how many plates came more than once
+func Visitors(lotID uint, from string, to string, tz string) (models.VisitorStats, error) {
+	var stats models.VisitorStats
+	err := db.Raw(`
+		WITH visits AS (
+			SELECT plate, COUNT(*) AS entries
+			FROM parkings
+			WHERE deleted_at IS NULL AND lot_id = ?
+				AND checkin >= (?::timestamp AT TIME ZONE ?)
+				AND checkin < ((?::timestamp + interval '1 day') AT TIME ZONE ?)
+			GROUP BY plate
+		)
+		SELECT COALESCE(SUM(entries), 0) AS entries,
+			COUNT(*) AS plates,
+			COUNT(*) FILTER (WHERE entries > 1) AS repeated
+		FROM visits`, lotID, from, tz, to, tz).Scan(&stats).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return stats, utils.ErrInternalServer
+	}
+
+	return stats, nil
+}
+
+// ExportPage gets up to limit tickets of a lot that started from day to day with ids after afterID, joined with their payments
+func ExportPage(lotID uint, from string, to string, tz string, afterID uint, limit int) ([]models.ExportRow, error) {
+	var rows []models.ExportRow
+	err := db.Raw(`
+		SELECT parkings.id, parkings.plate, parkings.checkin, parkings.checkout, parkings.prepaid,
+			COALESCE(payments.paid, false) AS paid,
+			COALESCE(payments.amount, 0) AS amount,
+			COALESCE(payments.method, '') AS method,
+			payments.created_at AS paid_at
+		FROM parkings
+		LEFT JOIN payments ON payments.parking_id = parkings.id AND payments.deleted_at IS NULL
+		WHERE parkings.deleted_at IS NULL AND parkings.lot_id = ?
+			AND parkings.checkin >= (?::timestamp AT TIME ZONE ?)
+			AND parkings.checkin < ((?::timestamp + interval '1 day') AT TIME ZONE ?)
+			AND parkings.id > ?
+		ORDER BY parkings.id
+		LIMIT ?`, lotID, from, tz, to, tz, afterID, limit).Scan(&rows).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return rows, nil
+}
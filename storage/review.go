@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateReview stores an image check-in waiting for an operator
+func CreateReview(review models.Review) error {
+	review.Status = models.ReviewPending
+	if err := db.Create(&review).Error; err != nil {
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
+
+// GetReview gets a review of a lot by its token
+func GetReview(lotID uint, token string) (models.Review, error) {
+	var review models.Review
+	err := db.Scopes(inLot(lotID)).Where("token = ?", token).First(&review).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return review, utils.ErrNotFound
+		}
+		logrus.Warn(err.Error())
+		return review, utils.ErrInternalServer
+	}
+
+	return review, nil
+}
+
+// Reviews lists the reviews of a lot with a status, oldest capture first so the car waiting longest comes first
+func Reviews(lotID uint, status string) ([]models.Review, error) {
+	var reviews []models.Review
+	err := db.Scopes(inLot(lotID)).Where("status = ?", status).Order("captured_at, id").Find(&reviews).Error
+	if err != nil {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return reviews, nil
+}
+
+// AcceptReview checks the plate in at the time the image was captured and resolves the review as accepted or edited
+func AcceptReview(lotID uint, token string, plate string, status string, capacity models.Capacity, grace time.Duration) (uint, error) {
+	var parking models.Parking
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		review, err := lockReview(tx, lotID, token)
+		if err != nil {
+			return err
+		}
+
+		parking, err = checkin(tx, lotID, plate, "", review.Image, review.CapturedAt, capacity, grace)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&review).Updates(map[string]interface{}{
+			"status":      status,
+			"resolved_at": time.Now(),
+			"parking_id":  parking.ID,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) || errors.Is(err, utils.ErrReviewClosed) || errors.Is(err, utils.ErrLotFull) {
+			return 0, err
+		}
+		logrus.Warn(err.Error())
+		return 0, utils.ErrInternalServer
+	}
+
+	return parking.ID, nil
+}
+
+// RejectReview resolves a review of a lot without checking anything in
+func RejectReview(lotID uint, token string) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		review, err := lockReview(tx, lotID, token)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&review).Updates(map[string]interface{}{
+			"status":      models.ReviewRejected,
+			"resolved_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) || errors.Is(err, utils.ErrReviewClosed) {
+			return err
+		}
+		logrus.Warn(err.Error())
+		return utils.ErrInternalServer
+	}
+
+	return nil
+}
+
+// lockReview gets a pending review of a lot with SELECT ... FOR UPDATE, so it is resolved only once
+func lockReview(tx *gorm.DB, lotID uint, token string) (models.Review, error) {
+	var review models.Review
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(inLot(lotID)).Where("token = ?", token).First(&review).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return review, utils.ErrNotFound
+		}
+		return review, err
+	}
+	if review.Status != models.ReviewPending {
+		return review, utils.ErrReviewClosed
+	}
+
+	return review, nil
+}
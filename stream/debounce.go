@@ -0,0 +1,73 @@
+package stream
+
+import "time"
+
+// Sampler drops frames arriving sooner than its interval after the last one taken
+type Sampler struct {
+	interval time.Duration
+	last     time.Time
+}
+
+// NewSampler takes a frame every interval, zero takes them all
+func NewSampler(interval time.Duration) *Sampler {
+	return &Sampler{interval: interval}
+}
+
+// Take tells if a frame arriving at this time is read
+func (s *Sampler) Take(at time.Time) bool {
+	if !s.last.IsZero() && at.Sub(s.last) < s.interval {
+		return false
+	}
+
+	s.last = at
+	return true
+}
+
+// Debouncer turns the sightings of a car standing in front of the camera into a single event
+type Debouncer struct {
+	window  time.Duration
+	confirm int
+	plates  map[string]*sighting
+}
+
+type sighting struct {
+	last  time.Time
+	count int
+	fired bool
+}
+
+// NewDebouncer fires after confirm sightings, no more than window apart
+func NewDebouncer(window time.Duration, confirm int) *Debouncer {
+	return &Debouncer{
+		window:  window,
+		confirm: confirm,
+		plates:  map[string]*sighting{},
+	}
+}
+
+// Seen records a sighting and tells if the plate is stable. It is told on every sighting until
+// the plate fired, and again only after it was not seen for a whole window
+func (d *Debouncer) Seen(plate string, at time.Time) bool {
+	for p, s := range d.plates {
+		if at.Sub(s.last) > d.window {
+			delete(d.plates, p)
+		}
+	}
+
+	s, ok := d.plates[plate]
+	if !ok {
+		s = &sighting{}
+		d.plates[plate] = s
+	}
+	s.last = at
+	s.count++
+
+	return !s.fired && s.count >= d.confirm
+}
+
+// Fired marks a stable plate as handled, so a car standing in front of the camera is not handled again
+func (d *Debouncer) Fired(plate string) {
+	if s, ok := d.plates[plate]; ok {
+		s.fired = true
+	}
+}
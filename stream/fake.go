@@ -0,0 +1,49 @@
+package stream
+
+import (
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// Fake is a camera serving its frames as an MJPEG stream, once each, for tests and demos
+type Fake struct {
+	Frames [][]byte
+	// Interval is the time between frames
+	Interval time.Duration
+}
+
+// ServeHTTP streams the frames and ends the response
+func (f Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+parts.Boundary())
+	w.WriteHeader(http.StatusOK)
+
+	for i, frame := range f.Frames {
+		if i > 0 && f.Interval > 0 {
+			select {
+			case <-time.After(f.Interval):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":   {http.DetectContentType(frame)},
+			"Content-Length": {strconv.Itoa(len(frame))},
+		})
+		if err != nil {
+			return
+		}
+		if _, err := part.Write(frame); err != nil {
+			return
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	parts.Close()
+}
@@ -0,0 +1,124 @@
+package stream
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"br.com.mlabs/utils"
+)
+
+// Directions a camera may watch
+const (
+	Entry = "entry"
+	Exit  = "exit"
+)
+
+// maxFrame is the largest frame read, bigger ones end the stream
+const maxFrame = 10 << 20
+
+// Camera is an MJPEG stream watched for plates
+type Camera struct {
+	URL string `json:"url"`
+	// Direction is entry to check plates in or exit to check them out
+	Direction string `json:"direction"`
+	// FPS is how many frames are read per second, the others are dropped, zero reads them all
+	FPS float64 `json:"fps"`
+	// Window is how long, in seconds, a plate must be gone before it counts again, 30 by default
+	Window float64 `json:"window"`
+	// Confirm is how many sightings make a read stable, 2 by default
+	Confirm int `json:"confirm"`
+	// Lot is the name of the lot the camera is in, the default lot when empty
+	Lot string `json:"lot"`
+}
+
+// Interval is the time between sampled frames
+func (c Camera) Interval() time.Duration {
+	if c.FPS <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / c.FPS)
+}
+
+// DebounceWindow is how long a plate must be gone before it counts again
+func (c Camera) DebounceWindow() time.Duration {
+	if c.Window <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Window * float64(time.Second))
+}
+
+// Confirmations is how many sightings make a read stable
+func (c Camera) Confirmations() int {
+	if c.Confirm <= 0 {
+		return 2
+	}
+	return c.Confirm
+}
+
+// Reader splits a multipart/x-mixed-replace stream into its frames
+type Reader struct {
+	body  io.ReadCloser
+	parts *multipart.Reader
+}
+
+// NewReader reads the frames of a stream body with its Content-Type
+func NewReader(body io.ReadCloser, contentType string) (*Reader, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, utils.ErrStreamNotValid
+	}
+
+	// Some cameras repeat the dashes of the delimiter in the boundary
+	boundary := strings.TrimPrefix(params["boundary"], "--")
+
+	return &Reader{body: body, parts: multipart.NewReader(body, boundary)}, nil
+}
+
+// Connect opens the stream at url
+func Connect(client *http.Client, url string) (*Reader, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, utils.ErrStreamNotValid
+	}
+
+	reader, err := NewReader(res.Body, res.Header.Get("Content-Type"))
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// Next waits for the next frame, io.EOF when the stream ended
+func (r *Reader) Next() ([]byte, error) {
+	part, err := r.parts.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+
+	frame, err := ioutil.ReadAll(io.LimitReader(part, maxFrame+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) > maxFrame {
+		return nil, utils.ErrImageTooLarge
+	}
+
+	return frame, nil
+}
+
+// Close drops the connection, a Next waiting on it returns
+func (r *Reader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,146 @@
+package stream_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"br.com.mlabs/stream"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader(t *testing.T) {
+	jpeg, _ := ioutil.ReadFile("../assets/download.jpg")
+	png, _ := ioutil.ReadFile("../assets/download.png")
+	server := httptest.NewServer(stream.Fake{Frames: [][]byte{jpeg, png, jpeg}})
+	defer server.Close()
+
+	reader, err := stream.Connect(http.DefaultClient, server.URL)
+	assert.Equal(t, err, nil)
+	defer reader.Close()
+
+	for _, expected := range [][]byte{jpeg, png, jpeg} {
+		frame, err := reader.Next()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, frame, expected)
+	}
+
+	_, err = reader.Next()
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestReaderBoundaryDashes(t *testing.T) {
+	body := "--frame\r\nContent-Type: image/jpeg\r\n\r\none\r\n--frame\r\nContent-Type: image/jpeg\r\n\r\ntwo\r\n--frame--\r\n"
+	reader, err := stream.NewReader(ioutil.NopCloser(strings.NewReader(body)), "multipart/x-mixed-replace;boundary=--frame")
+	assert.Equal(t, err, nil)
+
+	frame, _ := reader.Next()
+	assert.Equal(t, string(frame), "one")
+	frame, _ = reader.Next()
+	assert.Equal(t, string(frame), "two")
+}
+
+func TestConnectNotValid(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("a single frame"))
+	}))
+	defer server.Close()
+
+	_, err := stream.Connect(http.DefaultClient, server.URL)
+	assert.Equal(t, err, utils.ErrStreamNotValid)
+
+	_, err = stream.Connect(http.DefaultClient, server.URL+"/missing\x7f")
+	assert.NotEqual(t, err, nil)
+}
+
+func TestCloseStopsNext(t *testing.T) {
+	jpeg, _ := ioutil.ReadFile("../assets/download.jpg")
+	server := httptest.NewServer(stream.Fake{Frames: [][]byte{jpeg, jpeg}, Interval: 2 * time.Second})
+	defer server.Close()
+
+	reader, err := stream.Connect(http.DefaultClient, server.URL)
+	assert.Equal(t, err, nil)
+	_, err = reader.Next()
+	assert.Equal(t, err, nil)
+
+	done := make(chan error)
+	go func() {
+		_, err := reader.Next()
+		done <- err
+	}()
+	reader.Close()
+
+	select {
+	case err := <-done:
+		assert.NotEqual(t, err, nil)
+	case <-time.After(time.Second):
+		t.Fatal("Next waited for the next frame after Close")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	start := time.Now()
+	sampler := stream.NewSampler(time.Second)
+
+	assert.Equal(t, sampler.Take(start), true)
+	assert.Equal(t, sampler.Take(start.Add(500*time.Millisecond)), false)
+	assert.Equal(t, sampler.Take(start.Add(time.Second)), true)
+	assert.Equal(t, sampler.Take(start.Add(1900*time.Millisecond)), false)
+	assert.Equal(t, sampler.Take(start.Add(2*time.Second)), true)
+
+	all := stream.NewSampler(0)
+	assert.Equal(t, all.Take(start), true)
+	assert.Equal(t, all.Take(start), true)
+}
+
+func TestDebouncer(t *testing.T) {
+	start := time.Now()
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+	debouncer := stream.NewDebouncer(10*time.Second, 2)
+
+	// A single sighting may be a misread
+	assert.Equal(t, debouncer.Seen("GTJ-6699", at(0)), false)
+	assert.Equal(t, debouncer.Seen("GTJ-6699", at(1)), true)
+
+	// Not handled yet, it is told again
+	assert.Equal(t, debouncer.Seen("GTJ-6699", at(2)), true)
+	debouncer.Fired("GTJ-6699")
+
+	// The car stands in front of the camera
+	assert.Equal(t, debouncer.Seen("GTJ-6699", at(3)), false)
+	assert.Equal(t, debouncer.Seen("GTJ-6699", at(12)), false)
+
+	// Other plates count on their own
+	assert.Equal(t, debouncer.Seen("BRA3R52", at(13)), false)
+	assert.Equal(t, debouncer.Seen("BRA3R52", at(14)), true)
+	debouncer.Fired("BRA3R52")
+
+	// Gone for a whole window, the car came back
+	assert.Equal(t, debouncer.Seen("GTJ-6699", at(30)), false)
+	assert.Equal(t, debouncer.Seen("GTJ-6699", at(31)), true)
+
+	// Sightings too far apart never confirm
+	assert.Equal(t, debouncer.Seen("ABC-1234", at(40)), false)
+	assert.Equal(t, debouncer.Seen("ABC-1234", at(60)), false)
+	assert.Equal(t, debouncer.Seen("ABC-1234", at(80)), false)
+}
+
+func TestCameraDefaults(t *testing.T) {
+	camera := stream.Camera{}
+	assert.Equal(t, camera.Interval(), time.Duration(0))
+	assert.Equal(t, camera.DebounceWindow(), 30*time.Second)
+	assert.Equal(t, camera.Confirmations(), 2)
+
+	camera = stream.Camera{FPS: 4, Window: 1.5, Confirm: 3}
+	assert.Equal(t, camera.Interval(), 250*time.Millisecond)
+	assert.Equal(t, camera.DebounceWindow(), 1500*time.Millisecond)
+	assert.Equal(t, camera.Confirmations(), 3)
+}
@@ -0,0 +1,98 @@
+package ticket
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+const timeFormat = "02/01/2006 15:04"
+
+// Ticket holds what is printed on an entrance ticket
+type Ticket struct {
+	Lot     string
+	ID      uint
+	Plate   string
+	Checkin time.Time
+	Token   string
+}
+
+// PDF renders the ticket as an 80mm wide PDF page
+func PDF(t Ticket) ([]byte, error) {
+	qr, err := qrcode.Encode(t.Token, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: 80, Ht: 130},
+	})
+	pdf.SetMargins(5, 5, 5)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(70, 10, translate(t.Lot), "", 1, "C", false, 0, "")
+
+	pdf.SetFont("Helvetica", "B", 22)
+	pdf.CellFormat(70, 12, t.Plate, "", 1, "C", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(70, 6, fmt.Sprintf("Ticket #%d", t.ID), "", 1, "C", false, 0, "")
+	pdf.CellFormat(70, 6, "Check-in: "+t.Checkin.Format(timeFormat), "", 1, "C", false, 0, "")
+
+	pdf.RegisterImageOptionsReader("qr", gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(qr))
+	pdf.ImageOptions("qr", 15, pdf.GetY()+4, 50, 50, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	pdf.SetY(pdf.GetY() + 58)
+
+	pdf.SetFont("Courier", "", 8)
+	pdf.CellFormat(70, 4, t.Token, "", 1, "C", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ESCPOS renders the ticket as ESC/POS commands for thermal printers, the QR code is drawn by the printer
+func ESCPOS(t Ticket) []byte {
+	var buf bytes.Buffer
+
+	buf.Write([]byte{0x1b, 0x40})       // initialize
+	buf.Write([]byte{0x1b, 0x61, 0x01}) // center
+
+	buf.Write([]byte{0x1b, 0x45, 0x01, 0x1d, 0x21, 0x11}) // bold, double size
+	buf.WriteString(t.Lot + "\n")
+	buf.WriteString(t.Plate + "\n")
+	buf.Write([]byte{0x1b, 0x45, 0x00, 0x1d, 0x21, 0x00}) // normal
+
+	buf.WriteString(fmt.Sprintf("Ticket #%d\n", t.ID))
+	buf.WriteString("Check-in: " + t.Checkin.Format(timeFormat) + "\n\n")
+
+	writeQR(&buf, t.Token)
+	buf.WriteString(t.Token + "\n")
+
+	buf.Write([]byte{0x1b, 0x64, 0x03})       // feed 3 lines
+	buf.Write([]byte{0x1d, 0x56, 0x42, 0x00}) // partial cut
+
+	return buf.Bytes()
+}
+
+func writeQR(buf *bytes.Buffer, data string) {
+	size := len(data) + 3
+
+	buf.Write([]byte{0x1d, 0x28, 0x6b, 0x04, 0x00, 0x31, 0x41, 0x32, 0x00}) // model 2
+	buf.Write([]byte{0x1d, 0x28, 0x6b, 0x03, 0x00, 0x31, 0x43, 0x06})       // module size
+	buf.Write([]byte{0x1d, 0x28, 0x6b, 0x03, 0x00, 0x31, 0x45, 0x31})       // error correction M
+	buf.Write([]byte{0x1d, 0x28, 0x6b, byte(size % 256), byte(size / 256), 0x31, 0x50, 0x30})
+	buf.WriteString(data)
+	buf.Write([]byte{0x1d, 0x28, 0x6b, 0x03, 0x00, 0x31, 0x51, 0x30}) // print
+	buf.WriteString("\n")
+}
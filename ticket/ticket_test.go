@@ -0,0 +1,49 @@
+package ticket_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"br.com.mlabs/ticket"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToken(t *testing.T) {
+	token := ticket.Token(42)
+
+	id, err := ticket.ParseToken(token)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, id, uint(42))
+
+	// Signature of another ticket
+	_, err = ticket.ParseToken("43" + token[2:])
+	assert.Equal(t, err, utils.ErrTicketNotValid)
+
+	_, err = ticket.ParseToken("42")
+	assert.Equal(t, err, utils.ErrTicketNotValid)
+
+	_, err = ticket.ParseToken("abc.def")
+	assert.Equal(t, err, utils.ErrTicketNotValid)
+}
+
+func TestRender(t *testing.T) {
+	tkt := ticket.Ticket{
+		Lot:     "Estacionamento São Paulo",
+		ID:      42,
+		Plate:   "ABC-1234",
+		Checkin: time.Date(2020, 11, 10, 14, 30, 0, 0, time.UTC),
+		Token:   ticket.Token(42),
+	}
+
+	pdf, err := ticket.PDF(tkt)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bytes.HasPrefix(pdf, []byte("%PDF")), true)
+
+	escpos := ticket.ESCPOS(tkt)
+	assert.Equal(t, bytes.HasPrefix(escpos, []byte{0x1b, 0x40}), true)
+	assert.Equal(t, bytes.Contains(escpos, []byte("ABC-1234")), true)
+	assert.Equal(t, bytes.Contains(escpos, []byte("10/11/2020 14:30")), true)
+	assert.Equal(t, bytes.Contains(escpos, []byte(tkt.Token)), true)
+}
@@ -0,0 +1,44 @@
+package ticket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/utils"
+)
+
+// Token signs a ticket id so it can be printed and read back at the gates
+func Token(id uint) string {
+	return fmt.Sprintf("%d.%s", id, sign(id))
+}
+
+// ParseToken returns the ticket id of a signed token
+func ParseToken(token string) (uint, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return 0, utils.ErrTicketNotValid
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, utils.ErrTicketNotValid
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(sign(uint(id)))) {
+		return 0, utils.ErrTicketNotValid
+	}
+
+	return uint(id), nil
+}
+
+func sign(id uint) string {
+	mac := hmac.New(sha256.New, config.TicketSecret())
+	fmt.Fprintf(mac, "ticket:%d", id)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
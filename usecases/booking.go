@@ -0,0 +1,94 @@
+package usecases
+
+import (
+	"strconv"
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// MakeBooking books a space for a future time window
+func MakeBooking(caller models.Caller, request models.BookingRequest) (uint, error) {
+	request.Plate = caller.Lot.NormalizePlate(request.Plate)
+	fields := caller.Lot.Check(request)
+	if !request.StartsAt.IsZero() && !request.StartsAt.After(time.Now()) {
+		fields = append(fields, models.FieldError{
+			Field:   "starts_at",
+			Rule:    "gtfield",
+			Value:   request.StartsAt,
+			Message: "must be after now",
+		})
+	}
+	if fields != nil {
+		return 0, models.Invalid{Err: utils.ErrBookingNotValid, Fields: fields}
+	}
+
+	return storage.CreateBooking(caller.Lot.ID, request, config.LotCapacity(caller.Lot), config.NoShowGrace())
+}
+
+// CancelBooking cancels a booking that has not been used yet
+func CancelBooking(caller models.Caller, idVar string) error {
+	id, err := strconv.ParseUint(idVar, 10, 64)
+	if err != nil {
+		return utils.ErrIDNotValid
+	}
+
+	return storage.CancelBooking(caller.Lot.ID, uint(id))
+}
+
+// GetBookings lists bookings, optionally filtered by plate and status
+func GetBookings(caller models.Caller, plate string, status string) ([]models.BookingEntry, error) {
+	if plate != "" {
+		plate = caller.Lot.NormalizePlate(plate)
+	}
+	if plate != "" && !caller.Lot.Validate(models.ParkingRequest{Plate: plate}) {
+		return nil, utils.ErrPlateNotValid
+	}
+
+	bookings, err := storage.Bookings(caller.Lot.ID, plate, status)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []models.BookingEntry{}
+	for _, booking := range bookings {
+		entries = append(entries, models.BookingEntry{
+			ID:        booking.ID,
+			Plate:     booking.Plate,
+			Category:  booking.Category,
+			StartsAt:  booking.StartsAt,
+			EndsAt:    booking.EndsAt,
+			Prepaid:   booking.Prepaid,
+			Status:    booking.Status,
+			ParkingID: booking.ParkingID,
+		})
+	}
+
+	return entries, nil
+}
+
+// ExpireBookings expires the bookings whose plate did not arrive in time
+func ExpireBookings() (int64, error) {
+	return storage.ExpireBookings(time.Now().Add(-config.NoShowGrace()))
+}
+
+// StartBookingExpiry runs ExpireBookings periodically, it never returns
+func StartBookingExpiry() {
+	ticker := time.NewTicker(config.BookingExpiryInterval())
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := ExpireBookings()
+		if err != nil {
+			logrus.Warn("Error expiring bookings")
+			continue
+		}
+		if expired > 0 {
+			logrus.Infof("Expired %d no-show bookings", expired)
+		}
+	}
+}
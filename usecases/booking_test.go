@@ -0,0 +1,176 @@
+package usecases_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeBooking(t *testing.T) {
+	request := models.BookingRequest{
+		Plate:    "BKG-1234",
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(2 * time.Hour),
+	}
+
+	id, err := usecases.MakeBooking(caller, request)
+	assert.Equal(t, err, nil)
+	assert.Greater(t, id, uint(0))
+
+	request.Plate = "ab"
+	id, err = usecases.MakeBooking(caller, request)
+	assert.Equal(t, errors.Is(err, utils.ErrBookingNotValid), true)
+	assert.Equal(t, id, uint(0))
+
+	// Window in the past
+	request.Plate = "BKG-1234"
+	request.StartsAt = time.Now().Add(-time.Hour)
+	id, err = usecases.MakeBooking(caller, request)
+	assert.Equal(t, errors.Is(err, utils.ErrBookingNotValid), true)
+	assert.Equal(t, id, uint(0))
+	fields := err.(models.Invalid).Fields
+	assert.Equal(t, len(fields), 1)
+	assert.Equal(t, fields[0].Field, "starts_at")
+	assert.Equal(t, fields[0].Rule, "gtfield")
+
+	// Window ending before it starts
+	request.StartsAt = time.Now().Add(2 * time.Hour)
+	request.EndsAt = time.Now().Add(time.Hour)
+	id, err = usecases.MakeBooking(caller, request)
+	assert.Equal(t, errors.Is(err, utils.ErrBookingNotValid), true)
+	assert.Equal(t, id, uint(0))
+}
+
+func TestMakeBookingParkedCars(t *testing.T) {
+	tx.Create(&models.Parking{LotID: caller.Lot.ID, Plate: "BKG-7777", Checkin: time.Now()})
+
+	// The cars parked right now fill the lot
+	startsAt, endsAt := time.Now().Add(5*time.Minute), time.Now().Add(time.Hour)
+	var parked, held int64
+	tx.Model(&models.Parking{}).Where("lot_id = ? AND checkout IS NULL", caller.Lot.ID).Count(&parked)
+	tx.Model(&models.Booking{}).Where("lot_id = ? AND status = ? AND starts_at < ? AND ends_at > ?", caller.Lot.ID, models.BookingBooked, endsAt, startsAt).Count(&held)
+	os.Setenv("PARKING_CAPACITY", strconv.Itoa(int(parked+held)))
+	defer os.Unsetenv("PARKING_CAPACITY")
+
+	// Within the no-show grace the booking could be used at once
+	_, err := usecases.MakeBooking(caller, models.BookingRequest{Plate: "BKG-8888", StartsAt: startsAt, EndsAt: endsAt})
+	assert.Equal(t, err, utils.ErrLotFull)
+
+	// Far enough ahead the cars will be gone
+	id, err := usecases.MakeBooking(caller, models.BookingRequest{
+		Plate:    "BKG-8888",
+		StartsAt: time.Now().AddDate(0, 0, 30),
+		EndsAt:   time.Now().AddDate(0, 0, 30).Add(time.Hour),
+	})
+	assert.Equal(t, err, nil)
+	assert.Greater(t, id, uint(0))
+}
+
+func TestCancelBooking(t *testing.T) {
+	assert.Equal(t, usecases.CancelBooking(caller, "notvalid"), utils.ErrIDNotValid)
+	assert.Equal(t, usecases.CancelBooking(caller, "1000"), utils.ErrNotFound)
+
+	booking := models.Booking{
+		LotID:    caller.Lot.ID,
+		Plate:    "BKG-2222",
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(2 * time.Hour),
+		Status:   models.BookingBooked,
+	}
+	tx.Create(&booking)
+
+	assert.Equal(t, usecases.CancelBooking(caller, fmt.Sprint(booking.ID)), nil)
+	assert.Equal(t, usecases.CancelBooking(caller, fmt.Sprint(booking.ID)), utils.ErrBookingClosed)
+}
+
+func TestGetBookings(t *testing.T) {
+	bookings, err := usecases.GetBookings(caller, "ab", "")
+	assert.Equal(t, err, utils.ErrPlateNotValid)
+	assert.Equal(t, bookings, []models.BookingEntry(nil))
+
+	bookings, err = usecases.GetBookings(caller, "BKG-3333", "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bookings, []models.BookingEntry{})
+
+	booking := models.Booking{
+		LotID:    caller.Lot.ID,
+		Plate:    "BKG-3333",
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(2 * time.Hour),
+		Prepaid:  1500,
+		Status:   models.BookingBooked,
+	}
+	tx.Create(&booking)
+
+	bookings, err = usecases.GetBookings(caller, "BKG-3333", models.BookingBooked)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(bookings), 1)
+	assert.Equal(t, bookings[0].ID, booking.ID)
+	assert.Equal(t, bookings[0].Prepaid, int64(1500))
+
+	bookings, err = usecases.GetBookings(caller, "BKG-3333", models.BookingCancelled)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(bookings), 0)
+}
+
+func TestExpireBookings(t *testing.T) {
+	noShow := models.Booking{
+		LotID:    caller.Lot.ID,
+		Plate:    "BKG-4444",
+		StartsAt: time.Now().Add(-2 * time.Hour),
+		EndsAt:   time.Now().Add(time.Hour),
+		Status:   models.BookingBooked,
+	}
+	tx.Create(&noShow)
+
+	upcoming := models.Booking{
+		LotID:    caller.Lot.ID,
+		Plate:    "BKG-4444",
+		StartsAt: time.Now().Add(time.Hour),
+		EndsAt:   time.Now().Add(2 * time.Hour),
+		Status:   models.BookingBooked,
+	}
+	tx.Create(&upcoming)
+
+	expired, err := usecases.ExpireBookings()
+	assert.Equal(t, err, nil)
+	assert.GreaterOrEqual(t, expired, int64(1))
+
+	tx.First(&noShow, noShow.ID)
+	assert.Equal(t, noShow.Status, models.BookingExpired)
+
+	tx.First(&upcoming, upcoming.ID)
+	assert.Equal(t, upcoming.Status, models.BookingBooked)
+}
+
+func TestMakeReservationConvertsBooking(t *testing.T) {
+	booking := models.Booking{
+		LotID:    caller.Lot.ID,
+		Plate:    "BKG-5555",
+		StartsAt: time.Now().Add(-5 * time.Minute),
+		EndsAt:   time.Now().Add(time.Hour),
+		Prepaid:  2000,
+		Status:   models.BookingBooked,
+	}
+	tx.Create(&booking)
+
+	id, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "BKG-5555"})
+	assert.Equal(t, err, nil)
+
+	var parking models.Parking
+	tx.First(&parking, id)
+	assert.Equal(t, *parking.BookingID, booking.ID)
+	assert.Equal(t, parking.Prepaid, int64(2000))
+
+	tx.First(&booking, booking.ID)
+	assert.Equal(t, booking.Status, models.BookingConverted)
+	assert.Equal(t, *booking.ParkingID, id)
+}
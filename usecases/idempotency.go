@@ -0,0 +1,25 @@
+package usecases
+
+import (
+	"time"
+
+	"br.com.mlabs/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// StartIdempotencyPurge deletes expired idempotency keys every hour, it never returns
+func StartIdempotencyPurge() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := storage.PurgeIdempotencyKeys()
+		if err != nil {
+			logrus.Warn("Error purging idempotency keys")
+			continue
+		}
+		if purged > 0 {
+			logrus.Infof("Purged %d idempotency keys", purged)
+		}
+	}
+}
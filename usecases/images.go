@@ -0,0 +1,184 @@
+package usecases
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/images"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var imageStore images.Store = images.Local{Dir: "uploads"}
+
+// SetImageStore chooses where uploaded images are kept
+func SetImageStore(store images.Store) {
+	imageStore = store
+}
+
+// CheckinImage stores an image, reads its plate and checks it in. When nothing could be read, the plate is not valid
+// or it was read with less than the minimum confidence, the image is queued for an operator under the review token
+func CheckinImage(caller models.Caller, data []byte, camera string) (models.CheckinResult, error) {
+	capturedAt := time.Now()
+
+	key, base, err := storeImage(data)
+	if err != nil {
+		return models.CheckinResult{}, err
+	}
+
+	recognized, err := RecognizeImage(caller, data, base, camera)
+	if err != nil && err != utils.ErrImageRecognition {
+		discardImage(key)
+		return models.CheckinResult{}, err
+	}
+
+	result, err := checkinRecognition(caller, recognized, err == nil, key, capturedAt)
+	if err != nil {
+		discardImage(key)
+	}
+
+	return result, err
+}
+
+// CheckinImages stores an image and checks in every plate found in it, for cameras covering more than one lane.
+// Each plate gets its ticket or its review, a plate that could not be checked in keeps its error and the others go on.
+// When no valid plate is found, the best reading waits for an operator as in CheckinImage.
+func CheckinImages(caller models.Caller, data []byte, camera string) ([]models.CheckinResult, error) {
+	capturedAt := time.Now()
+
+	key, base, err := storeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := readRegions(caller.Lot, data, base, camera)
+	plates := validPlates(caller.Lot, regions)
+	if len(plates) == 0 {
+		var readings []reading
+		for _, r := range regions {
+			readings = append(readings, r...)
+		}
+
+		var recognized models.Recognition
+		if len(readings) > 0 {
+			recognized = bestReading(sortReadings(readings))
+		}
+
+		result, err := checkinRecognition(caller, recognized, len(readings) > 0, key, capturedAt)
+		if err != nil {
+			discardImage(key)
+			return nil, err
+		}
+		return []models.CheckinResult{result}, nil
+	}
+
+	var res []models.CheckinResult
+	failed := 0
+	for _, recognized := range plates {
+		result, err := checkinRecognition(caller, recognized, true, key, capturedAt)
+		if err != nil {
+			result.Err, result.Error = err, err.Error()
+			failed++
+		}
+		res = append(res, result)
+	}
+
+	if failed == len(res) {
+		discardImage(key)
+	}
+
+	return res, nil
+}
+
+// storeImage keeps an upload in the image store, returning its key and where its debug stages go
+func storeImage(data []byte) (string, string, error) {
+	key := images.Key(data)
+	if err := imageStore.Put(key, data); err != nil {
+		logrus.Warn(err.Error())
+		return "", "", utils.ErrInternalServer
+	}
+
+	return key, debugBase(key), nil
+}
+
+// discardImage deletes an image no check-in kept, unless a ticket or a review already points at the same content
+func discardImage(key string) {
+	used, err := storage.ImageUsed(key)
+	if err != nil || used {
+		return
+	}
+
+	if err := imageStore.Delete(key); err != nil {
+		logrus.Warn(err.Error())
+	}
+}
+
+// debugBase is where the debug stages of an image go, named by its key
+func debugBase(key string) string {
+	if config.OCRDebug() {
+		if err := os.MkdirAll(config.OCRDebugDir(), 0755); err != nil {
+			logrus.Warn(err.Error())
+		}
+	}
+
+	return filepath.Join(config.OCRDebugDir(), strings.TrimSuffix(key, filepath.Ext(key)))
+}
+
+// checkinRecognition checks a reading in, or queues it for review when it was not read, is not valid or is not confident enough
+func checkinRecognition(caller models.Caller, recognized models.Recognition, read bool, key string, capturedAt time.Time) (models.CheckinResult, error) {
+	var err error
+	result := models.CheckinResult{Recognition: recognized}
+	request := models.ParkingRequest{Plate: recognized.Plate, Image: key}
+	if !read || !caller.Lot.Validate(request) || recognized.Confidence < config.OCRMinConfidence() {
+		result.Review, err = createReview(caller, recognized, key, capturedAt)
+		return result, err
+	}
+
+	result.ID, err = MakeReservation(caller, request)
+	return result, err
+}
+
+// PurgeImages deletes the images of tickets and resolved reviews of every lot older than the retention
+func PurgeImages() (int, error) {
+	keys, err := storage.ExpiredImages(time.Now().AddDate(0, 0, -config.ImageRetentionDays()))
+	if err != nil {
+		return 0, err
+	}
+
+	var purged []string
+	for _, key := range keys {
+		if err := imageStore.Delete(key); err != nil {
+			logrus.Warn(err.Error())
+			continue
+		}
+		purged = append(purged, key)
+	}
+
+	return len(purged), storage.ForgetImages(purged)
+}
+
+// StartImagePurge purges expired images every hour, it never returns and does nothing when retention is off
+func StartImagePurge() {
+	if config.ImageRetentionDays() <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := PurgeImages()
+		if err != nil {
+			logrus.Warn("Error purging images")
+			continue
+		}
+		if purged > 0 {
+			logrus.Infof("Purged %d images", purged)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package usecases_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"br.com.mlabs/images"
+	"br.com.mlabs/models"
+	"br.com.mlabs/recognition"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var imageStore images.Local
+
+// useTempImageStore keeps uploads in a temporary directory, the returned func removes it
+func useTempImageStore(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imageStore = images.Local{Dir: dir}
+	usecases.SetImageStore(imageStore)
+
+	return func() {
+		os.RemoveAll(dir)
+		usecases.SetImageStore(images.Local{Dir: "uploads"})
+	}
+}
+
+func TestCheckinImageStored(t *testing.T) {
+	image, _ := ioutil.ReadFile("../assets/download2.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(image): recognition.Certain("IMG3R52"),
+	}))
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	result, err := usecases.CheckinImage(caller, image, "")
+	assert.Equal(t, err, nil)
+
+	var parking models.Parking
+	tx.First(&parking, result.ID)
+	assert.Equal(t, parking.Image, images.Key(image))
+
+	stored, err := imageStore.Get(parking.Image)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, stored, image)
+}
+
+func TestCheckinImageFailed(t *testing.T) {
+	// Trailing bytes make a content no ticket points at yet
+	image, _ := ioutil.ReadFile("../assets/download2.jpg")
+	image = append(image, []byte("lot full")...)
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(image): recognition.Certain("FUL3R52"),
+	}))
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	var parked, held int64
+	tx.Model(&models.Parking{}).Where("checkout IS NULL").Count(&parked)
+	tx.Model(&models.Booking{}).Where("status = ? AND starts_at <= now() AND ends_at > now()", models.BookingBooked).Count(&held)
+	os.Setenv("PARKING_CAPACITY", strconv.Itoa(int(parked+held)))
+	defer os.Unsetenv("PARKING_CAPACITY")
+
+	_, err := usecases.CheckinImage(caller, image, "")
+	assert.Equal(t, err, utils.ErrLotFull)
+	_, err = imageStore.Get(images.Key(image))
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	results, err := usecases.CheckinImages(caller, image, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, results[0].Err, utils.ErrLotFull)
+	_, err = imageStore.Get(images.Key(image))
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	// A ticket already points at the same content, so it stays
+	tx.Create(&models.Parking{LotID: caller.Lot.ID, Plate: "FUL-3521", Checkin: time.Now(), Image: images.Key(image)})
+	_, err = usecases.CheckinImage(caller, image, "")
+	assert.Equal(t, err, utils.ErrLotFull)
+	_, err = imageStore.Get(images.Key(image))
+	assert.Equal(t, err, nil)
+}
+
+func TestPurgeImages(t *testing.T) {
+	defer useTempImageStore(t)()
+
+	old, _ := ioutil.ReadFile("../assets/download.jpg")
+	shared, _ := ioutil.ReadFile("../assets/download.png")
+	recent, _ := ioutil.ReadFile("../assets/download2.png")
+	for _, image := range [][]byte{old, shared, recent} {
+		imageStore.Put(images.Key(image), image)
+	}
+
+	expired := models.Parking{LotID: caller.Lot.ID, Plate: "OLD-1234", Checkin: time.Now().AddDate(0, -3, 0), Image: images.Key(old)}
+	tx.Create(&expired)
+	// The same image was uploaded again lately, so it stays
+	tx.Create(&models.Parking{LotID: caller.Lot.ID, Plate: "OLD-4321", Checkin: time.Now().AddDate(0, -3, 0), Image: images.Key(shared)})
+	tx.Create(&models.Parking{LotID: caller.Lot.ID, Plate: "NEW-4321", Checkin: time.Now(), Image: images.Key(shared)})
+	// Pending reviews keep their image whatever their age
+	tx.Create(&models.Review{LotID: caller.Lot.ID, Token: "purge-token", Image: images.Key(recent), Status: models.ReviewPending, CapturedAt: time.Now().AddDate(0, -3, 0)})
+
+	purged, err := usecases.PurgeImages()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, purged, 1)
+
+	_, err = imageStore.Get(images.Key(old))
+	assert.Equal(t, err, utils.ErrNotFound)
+	tx.First(&expired, expired.ID)
+	assert.Equal(t, expired.Image, "")
+
+	_, err = imageStore.Get(images.Key(shared))
+	assert.Equal(t, err, nil)
+	_, err = imageStore.Get(images.Key(recent))
+	assert.Equal(t, err, nil)
+}
+
+func TestCheckinImages(t *testing.T) {
+	frame := twoLanes(t)
+	usecases.SetRecognizer(laneReader{whole: recognition.Hash(frame)})
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	results, err := usecases.CheckinImages(caller, frame, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(results), 2)
+	assert.Equal(t, results[0].Plate, "GTJ-6699")
+	assert.Greater(t, results[0].ID, uint(0))
+	assert.Equal(t, results[1].Plate, "BRA3R52")
+	assert.Greater(t, results[1].ID, results[0].ID)
+
+	// Left to right, each with its own box and confidence
+	assert.Equal(t, results[0].Confidence, 90.0)
+	assert.Equal(t, results[1].Confidence, 100.0)
+	assert.Equal(t, results[0].Box.X+results[0].Box.Width <= results[1].Box.X, true)
+
+	// Both tickets keep the same image
+	var parkings []models.Parking
+	tx.Find(&parkings, []uint{results[0].ID, results[1].ID})
+	assert.Equal(t, parkings[0].Image, images.Key(frame))
+	assert.Equal(t, parkings[1].Image, images.Key(frame))
+}
+
+func TestCheckinImagesPartial(t *testing.T) {
+	frame := twoLanes(t)
+	usecases.SetRecognizer(laneReader{whole: recognition.Hash(frame)})
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	// A single space left, the second lane is turned away
+	var parked, held int64
+	tx.Model(&models.Parking{}).Where("checkout IS NULL").Count(&parked)
+	tx.Model(&models.Booking{}).Where("status = ? AND starts_at <= now() AND ends_at > now()", models.BookingBooked).Count(&held)
+	os.Setenv("PARKING_CAPACITY", strconv.Itoa(int(parked+held)+1))
+	defer os.Unsetenv("PARKING_CAPACITY")
+
+	results, err := usecases.CheckinImages(caller, frame, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(results), 2)
+	assert.Greater(t, results[0].ID, uint(0))
+	assert.Equal(t, results[0].Error, "")
+	assert.Equal(t, results[1].ID, uint(0))
+	assert.Equal(t, results[1].Err, utils.ErrLotFull)
+	assert.Equal(t, results[1].Error, utils.ErrLotFull.Error())
+
+	// Nothing valid in the frame, the best reading waits for an operator
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(frame): {Text: "GTJ 6699 BRA 3R52", Confidence: 95},
+	}))
+	results, err = usecases.CheckinImages(caller, frame, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ID, uint(0))
+	assert.Equal(t, len(results[0].Review), 32)
+}
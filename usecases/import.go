@@ -0,0 +1,70 @@
+package usecases
+
+import (
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+)
+
+// Import applies the events a gate recorded while offline, with their original times.
+// Events are applied in the order they were sent and each event id is applied only once.
+func Import(caller models.Caller, request models.ImportRequest) ([]models.ImportResult, error) {
+	if err := caller.Lot.Verify(request, utils.ErrImportNotValid); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.ImportResult, len(request.Events))
+	for i := range request.Events {
+		results[i].EventID = request.Events[i].EventID
+
+		if err := prepareEvent(caller.Lot, &request.Events[i]); err != nil {
+			results[i].Status = models.ImportFailed
+			results[i].Error = err.Error()
+		}
+	}
+
+	chargeLot := func(parking models.Parking, at time.Time, method string) (models.Payment, error) {
+		return charge(caller.Lot, parking, at, method)
+	}
+
+	err := storage.ImportEvents(caller.Lot.ID, request.DeviceID, request.Events, results, config.NoShowGrace(), chargeLot)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// prepareEvent validates an event in the lot and resolves the ticket it points to
+func prepareEvent(lot models.Lot, event *models.ImportEvent) error {
+	if !lot.Validate(*event) || event.Time.After(time.Now().Add(time.Minute)) {
+		return utils.ErrEventNotValid
+	}
+
+	if event.Type == models.EventCheckin {
+		event.Plate = lot.NormalizePlate(event.Plate)
+		if !lot.Validate(models.ParkingRequest{Plate: event.Plate}) {
+			return utils.ErrPlateNotValid
+		}
+
+		return nil
+	}
+
+	if event.TicketEventID != "" {
+		return nil
+	}
+	if event.Ticket == "" {
+		return utils.ErrEventNotValid
+	}
+
+	id, err := parseID(event.Ticket)
+	if err != nil {
+		return err
+	}
+	event.ParkingID = id
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package usecases_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImport(t *testing.T) {
+	_, err := usecases.Import(caller, models.ImportRequest{DeviceID: "gate-1"})
+	assert.Equal(t, errors.Is(err, utils.ErrImportNotValid), true)
+
+	checkin := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	request := models.ImportRequest{
+		DeviceID: "gate-1",
+		Events: []models.ImportEvent{
+			{EventID: "imp-1", Type: models.EventCheckin, Time: checkin, Plate: "IMP-1234"},
+			{EventID: "imp-2", Type: models.EventPayment, Time: checkin.Add(2 * time.Hour), TicketEventID: "imp-1", Method: models.PaymentCash},
+			{EventID: "imp-3", Type: models.EventCheckout, Time: checkin.Add(150 * time.Minute), TicketEventID: "imp-1"},
+			{EventID: "imp-4", Type: models.EventCheckin, Time: checkin, Plate: "not a plate"},
+			{EventID: "imp-5", Type: models.EventCheckout, Time: checkin, TicketEventID: "imp-1"},
+			{EventID: "imp-6", Type: models.EventPayment, Time: checkin, Ticket: "999999"},
+		},
+	}
+
+	results, err := usecases.Import(caller, request)
+	assert.Equal(t, err, nil)
+
+	id := results[0].TicketID
+	assert.Greater(t, id, uint(0))
+	assert.Equal(t, results, []models.ImportResult{
+		{EventID: "imp-1", Status: models.ImportApplied, TicketID: id},
+		{EventID: "imp-2", Status: models.ImportApplied, TicketID: id},
+		{EventID: "imp-3", Status: models.ImportApplied, TicketID: id},
+		{EventID: "imp-4", Status: models.ImportFailed, Error: utils.ErrPlateNotValid.Error()},
+		{EventID: "imp-5", Status: models.ImportFailed, Error: utils.ErrAlreadyCheckedOut.Error()},
+		{EventID: "imp-6", Status: models.ImportFailed, Error: utils.ErrNotFound.Error()},
+	})
+
+	// Original times are kept
+	var parking models.Parking
+	tx.First(&parking, id)
+	assert.Equal(t, parking.Checkin.Equal(checkin), true)
+	assert.Equal(t, parking.Checkout.Equal(checkin.Add(150*time.Minute)), true)
+
+	var payment models.Payment
+	tx.Where("parking_id = ?", id).First(&payment)
+	assert.Equal(t, payment.CreatedAt.Equal(checkin.Add(2*time.Hour)), true)
+
+	// Sending the batch again changes nothing
+	results, err = usecases.Import(caller, request)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, results[0], models.ImportResult{EventID: "imp-1", Status: models.ImportDuplicate, TicketID: id})
+	assert.Equal(t, results[2], models.ImportResult{EventID: "imp-3", Status: models.ImportDuplicate, TicketID: id})
+
+	var parkings int64
+	tx.Model(&models.Parking{}).Where("plate = ?", "IMP-1234").Count(&parkings)
+	assert.Equal(t, parkings, int64(1))
+
+	// Failed events can be sent again
+	results, err = usecases.Import(caller, models.ImportRequest{
+		DeviceID: "gate-1",
+		Events: []models.ImportEvent{
+			{EventID: "imp-6", Type: models.EventPayment, Time: checkin, Ticket: fmt.Sprint(id)},
+		},
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, results[0], models.ImportResult{EventID: "imp-6", Status: models.ImportFailed, Error: utils.ErrAlreadyPaid.Error()})
+}
+
+func TestImportDevices(t *testing.T) {
+	checkin := time.Now().Add(-time.Hour).Truncate(time.Second)
+	first, err := usecases.Import(caller, models.ImportRequest{
+		DeviceID: "gate-a",
+		Events: []models.ImportEvent{
+			{EventID: "1", Type: models.EventCheckin, Time: checkin, Plate: "DEV-1234"},
+		},
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, first[0].Status, models.ImportApplied)
+
+	// Another gate numbers its events the same way, its check-in is not a duplicate
+	second, err := usecases.Import(caller, models.ImportRequest{
+		DeviceID: "gate-b",
+		Events: []models.ImportEvent{
+			{EventID: "1", Type: models.EventCheckin, Time: checkin, Plate: "DEV-5678"},
+			{EventID: "2", Type: models.EventCheckout, Time: checkin.Add(time.Minute), TicketEventID: "1"},
+		},
+	})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, second[0].Status, models.ImportApplied)
+	assert.NotEqual(t, second[0].TicketID, first[0].TicketID)
+	assert.Equal(t, second[1], models.ImportResult{EventID: "2", Status: models.ImportApplied, TicketID: second[0].TicketID})
+
+	var parking models.Parking
+	tx.First(&parking, first[0].TicketID)
+	assert.Equal(t, parking.Plate, "DEV-1234")
+	assert.Equal(t, parking.Checkout == nil, true)
+}
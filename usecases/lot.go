@@ -0,0 +1,238 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	defaultLot      models.Lot
+	defaultLotMutex sync.Mutex
+)
+
+// DefaultCaller is the operator of the lot named LOT_NAME, for requests without an API key and cameras without a lot.
+// The lot is created the first time and kept
+func DefaultCaller() (models.Caller, error) {
+	defaultLotMutex.Lock()
+	defer defaultLotMutex.Unlock()
+
+	if defaultLot.ID == 0 {
+		lot, err := storage.DefaultLot(config.LotName())
+		if err != nil {
+			return models.Caller{}, err
+		}
+		defaultLot = lot
+	}
+
+	return models.Caller{Lot: defaultLot, Role: models.RoleOperator}, nil
+}
+
+// Authenticate finds the user of an API key, calls are made in its lot
+func Authenticate(key string) (models.Caller, error) {
+	if key == "" {
+		return models.Caller{}, utils.ErrUnauthorized
+	}
+
+	user, err := storage.UserByKey(hashKey(key))
+	if err != nil {
+		return models.Caller{}, err
+	}
+
+	return models.Caller{Lot: user.Lot, Role: user.Role}, nil
+}
+
+// GetLots lists the lots an admin sees
+func GetLots(caller models.Caller) ([]models.LotEntry, error) {
+	if !caller.Admin() {
+		return nil, utils.ErrForbidden
+	}
+
+	lots, err := seenLots(caller)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []models.LotEntry{}
+	for _, lot := range lots {
+		entry := models.LotEntry{ID: lot.ID, Name: lot.Name, Capacity: lot.Capacity}
+		if lot.PlateFormats != "" {
+			entry.PlateFormats = strings.Split(lot.PlateFormats, ",")
+		}
+		if lot.Company != nil {
+			entry.Company = lot.Company.Name
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// MakeLot creates a lot in the company of an admin, admins of a lot without a company have nowhere to create it
+func MakeLot(caller models.Caller, request models.LotRequest) (uint, error) {
+	if !caller.Admin() || (caller.Lot.CompanyID == nil && !caller.SuperAdmin()) {
+		return 0, utils.ErrForbidden
+	}
+
+	return newLot(caller.Lot.CompanyID, request)
+}
+
+// CreateLot creates a lot in a company, found or created by name, or in none when it is empty
+func CreateLot(company string, request models.LotRequest) (uint, error) {
+	if company == "" {
+		return newLot(nil, request)
+	}
+
+	c, err := storage.Company(company)
+	if err != nil {
+		return 0, err
+	}
+
+	return newLot(&c.ID, request)
+}
+
+// MakeUser creates a user in a lot the admin sees, its key is only returned here
+func MakeUser(caller models.Caller, lotIDVar string, request models.UserRequest) (models.UserKey, error) {
+	if !caller.Admin() {
+		return models.UserKey{}, utils.ErrForbidden
+	}
+
+	id, err := strconv.ParseUint(lotIDVar, 10, 64)
+	if err != nil {
+		return models.UserKey{}, utils.ErrIDNotValid
+	}
+
+	lot, err := storage.GetLot(uint(id))
+	if err != nil {
+		return models.UserKey{}, err
+	}
+	if !sees(caller, lot) {
+		return models.UserKey{}, utils.ErrNotFound
+	}
+	if err := models.Verify(request, utils.ErrUserNotValid); err != nil {
+		return models.UserKey{}, err
+	}
+
+	return newUser(lot, request)
+}
+
+// CreateUser creates a user in a lot found by name, from the command line it may also be a super-admin
+func CreateUser(lotName string, request models.UserRequest) (models.UserKey, error) {
+	lot, err := storage.LotByName(lotName)
+	if err != nil {
+		return models.UserKey{}, err
+	}
+
+	// Checked as an admin, the API refuses the role
+	checked := request
+	if checked.Role == models.RoleSuperAdmin {
+		checked.Role = models.RoleAdmin
+	}
+	if err := models.Verify(checked, utils.ErrUserNotValid); err != nil {
+		return models.UserKey{}, err
+	}
+
+	return newUser(lot, request)
+}
+
+// newLot validates a lot and its categories, each listed once, and stores it
+func newLot(companyID *uint, request models.LotRequest) (uint, error) {
+	if err := models.Verify(request, utils.ErrLotNotValid); err != nil {
+		return 0, err
+	}
+
+	lot := models.Lot{
+		Name:         request.Name,
+		CompanyID:    companyID,
+		Capacity:     request.Capacity,
+		PlateFormats: strings.Join(request.PlateFormats, ","),
+	}
+	listed := map[string]bool{}
+	for i, c := range request.Categories {
+		if listed[c.Category] {
+			return 0, models.Invalid{Err: utils.ErrLotNotValid, Fields: []models.FieldError{{
+				Field:   fmt.Sprintf("categories[%d].category", i),
+				Rule:    "unique",
+				Value:   c.Category,
+				Message: "is listed more than once",
+			}}}
+		}
+		listed[c.Category] = true
+
+		lot.Categories = append(lot.Categories, models.LotCategory{
+			Category: c.Category,
+			Spaces:   c.Spaces,
+			Tariff: models.Tariff{
+				Grace:          time.Duration(c.GraceMinutes) * time.Minute,
+				FirstHour:      c.FirstHour,
+				AdditionalHour: c.AdditionalHour,
+				DailyMax:       c.DailyMax,
+			},
+		})
+	}
+
+	if err := storage.CreateLot(&lot); err != nil {
+		return 0, err
+	}
+
+	return lot.ID, nil
+}
+
+// newUser stores a checked user with a random key, keeping only its hash
+func newUser(lot models.Lot, request models.UserRequest) (models.UserKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		logrus.Warn(err.Error())
+		return models.UserKey{}, utils.ErrInternalServer
+	}
+	key := hex.EncodeToString(secret)
+
+	user := models.User{Name: request.Name, LotID: lot.ID, Role: request.Role, KeyHash: hashKey(key)}
+	if err := storage.CreateUser(&user); err != nil {
+		return models.UserKey{}, err
+	}
+
+	return models.UserKey{ID: user.ID, Key: key}, nil
+}
+
+// sees tells if a caller may work in a lot: its own, for admins any lot of its company, and for super-admins any lot
+func sees(caller models.Caller, lot models.Lot) bool {
+	if caller.Lot.ID == lot.ID || caller.SuperAdmin() {
+		return true
+	}
+	if !caller.Admin() {
+		return false
+	}
+
+	company := caller.Lot.CompanyID
+	return company != nil && lot.CompanyID != nil && *lot.CompanyID == *company
+}
+
+// seenLots are the lots an admin sees, only its own when its lot has no company
+func seenLots(caller models.Caller) ([]models.Lot, error) {
+	if caller.SuperAdmin() {
+		return storage.Lots(nil)
+	}
+	if caller.Lot.CompanyID == nil {
+		return []models.Lot{caller.Lot}, nil
+	}
+
+	return storage.Lots(caller.Lot.CompanyID)
+}
+
+// hashKey is what is stored of an API key
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
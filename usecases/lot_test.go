@@ -0,0 +1,244 @@
+package usecases_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/plates"
+	"br.com.mlabs/recognition"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// lotCaller creates a lot through the CLI path and authenticates a new user of it
+func lotCaller(t *testing.T, company string, request models.LotRequest, role string) models.Caller {
+	if _, err := usecases.CreateLot(company, request); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := usecases.CreateUser(request.Name, models.UserRequest{Name: "Test " + role, Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := usecases.Authenticate(key.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestMakeLot(t *testing.T) {
+	_, err := usecases.MakeLot(caller, models.LotRequest{Name: "UC Forbidden"})
+	assert.Equal(t, err, utils.ErrForbidden)
+
+	admin := lotCaller(t, "UC Company", models.LotRequest{Name: "UC Head"}, models.RoleAdmin)
+	assert.Equal(t, admin.Admin(), true)
+	assert.Equal(t, admin.Lot.Company.Name, "UC Company")
+
+	_, err = usecases.MakeLot(admin, models.LotRequest{})
+	assert.Equal(t, errors.Is(err, utils.ErrLotNotValid), true)
+
+	_, err = usecases.MakeLot(admin, models.LotRequest{Name: "UC Twice", Categories: []models.LotCategoryRequest{
+		{Category: models.CategoryCar, Spaces: 10},
+		{Category: models.CategoryCar, Spaces: 20},
+	}})
+	assert.Equal(t, errors.Is(err, utils.ErrLotNotValid), true)
+	assert.Equal(t, err.(models.Invalid).Fields[0].Field, "categories[1].category")
+
+	_, err = usecases.MakeLot(admin, models.LotRequest{Name: "UC Head"})
+	assert.Equal(t, err, utils.ErrLotExists)
+
+	id, err := usecases.MakeLot(admin, models.LotRequest{Name: "UC Branch"})
+	assert.Equal(t, err, nil)
+
+	lots, err := usecases.GetLots(admin)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, lots, []models.LotEntry{
+		{ID: id, Name: "UC Branch", Company: "UC Company"},
+		{ID: admin.Lot.ID, Name: "UC Head", Company: "UC Company"},
+	})
+
+	_, err = usecases.GetLots(caller)
+	assert.Equal(t, err, utils.ErrForbidden)
+}
+
+func TestMakeUser(t *testing.T) {
+	admin := lotCaller(t, "UC Users", models.LotRequest{Name: "UC Users Lot"}, models.RoleAdmin)
+	operator := lotCaller(t, "UC Others", models.LotRequest{Name: "UC Others Lot"}, models.RoleOperator)
+
+	_, err := usecases.MakeUser(operator, fmt.Sprint(operator.Lot.ID), models.UserRequest{Name: "Gate", Role: models.RoleOperator})
+	assert.Equal(t, err, utils.ErrForbidden)
+
+	_, err = usecases.MakeUser(admin, "notvalid", models.UserRequest{Name: "Gate", Role: models.RoleOperator})
+	assert.Equal(t, err, utils.ErrIDNotValid)
+
+	_, err = usecases.MakeUser(admin, fmt.Sprint(admin.Lot.ID), models.UserRequest{Name: "Gate", Role: "owner"})
+	assert.Equal(t, errors.Is(err, utils.ErrUserNotValid), true)
+
+	// A lot of another company is not seen
+	_, err = usecases.MakeUser(admin, fmt.Sprint(operator.Lot.ID), models.UserRequest{Name: "Gate", Role: models.RoleOperator})
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	key, err := usecases.MakeUser(admin, fmt.Sprint(admin.Lot.ID), models.UserRequest{Name: "Gate", Role: models.RoleOperator})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(key.Key), 64)
+
+	gate, err := usecases.Authenticate(key.Key)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, gate.Lot.ID, admin.Lot.ID)
+	assert.Equal(t, gate.Admin(), false)
+
+	_, err = usecases.Authenticate("notakey")
+	assert.Equal(t, err, utils.ErrUnauthorized)
+
+	_, err = usecases.Authenticate("")
+	assert.Equal(t, err, utils.ErrUnauthorized)
+}
+
+func TestAdminWithoutCompany(t *testing.T) {
+	admin := lotCaller(t, "", models.LotRequest{Name: "UC Alone"}, models.RoleAdmin)
+	other := lotCaller(t, "UC Elsewhere", models.LotRequest{Name: "UC Elsewhere Lot"}, models.RoleOperator)
+
+	// Only its own lot is seen
+	_, err := usecases.MakeUser(admin, fmt.Sprint(other.Lot.ID), models.UserRequest{Name: "Gate", Role: models.RoleOperator})
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	lots, err := usecases.GetLots(admin)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, lots, []models.LotEntry{{ID: admin.Lot.ID, Name: "UC Alone"}})
+
+	_, err = usecases.MakeLot(admin, models.LotRequest{Name: "UC Alone Branch"})
+	assert.Equal(t, err, utils.ErrForbidden)
+
+	_, err = usecases.MakeUser(admin, fmt.Sprint(admin.Lot.ID), models.UserRequest{Name: "Gate", Role: models.RoleOperator})
+	assert.Equal(t, err, nil)
+
+	// Super-admins are only created from the command line
+	_, err = usecases.MakeUser(admin, fmt.Sprint(admin.Lot.ID), models.UserRequest{Name: "Root", Role: models.RoleSuperAdmin})
+	assert.Equal(t, errors.Is(err, utils.ErrUserNotValid), true)
+
+	super := lotCaller(t, "", models.LotRequest{Name: "UC Super"}, models.RoleSuperAdmin)
+	assert.Equal(t, super.SuperAdmin(), true)
+
+	_, err = usecases.MakeUser(super, fmt.Sprint(other.Lot.ID), models.UserRequest{Name: "Gate", Role: models.RoleOperator})
+	assert.Equal(t, err, nil)
+
+	lots, err = usecases.GetLots(super)
+	assert.Equal(t, err, nil)
+	assert.Contains(t, lots, models.LotEntry{ID: other.Lot.ID, Name: "UC Elsewhere Lot", Company: "UC Elsewhere"})
+}
+
+func TestLotScoping(t *testing.T) {
+	capacity := 1
+	other := lotCaller(t, "", models.LotRequest{
+		Name:     "UC Scoped",
+		Capacity: &capacity,
+		Categories: []models.LotCategoryRequest{
+			{Category: models.CategoryCar, FirstHour: 900, AdditionalHour: 300},
+		},
+	}, models.RoleOperator)
+
+	id, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "LOT-1234"})
+	assert.Equal(t, err, nil)
+
+	// The ticket of the default lot is not found from the other one
+	_, err = usecases.GetTicket(other, fmt.Sprint(id))
+	assert.Equal(t, err, utils.ErrNotFound)
+	assert.Equal(t, usecases.Pay(other, fmt.Sprint(id), models.PaymentRequest{}), utils.ErrNotFound)
+	assert.Equal(t, usecases.Checkout(other, fmt.Sprint(id)), utils.ErrNotFound)
+
+	_, err = usecases.GetReservations(other, models.ParkingRequest{Plate: "LOT-1234"}, false)
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	// The same plate may be parked in both lots, the other lot is full after it
+	otherID, err := usecases.MakeReservation(other, models.ParkingRequest{Plate: "LOT-1234"})
+	assert.Equal(t, err, nil)
+
+	_, err = usecases.MakeReservation(other, models.ParkingRequest{Plate: "LOT-5678"})
+	assert.Equal(t, err, utils.ErrLotFull)
+
+	// Each lot charges its own tariff and numbers its own receipts
+	tx.Model(&models.Parking{}).Where("id = ?", otherID).Update("checkin", time.Now().Add(-90*time.Minute))
+	assert.Equal(t, usecases.Pay(other, fmt.Sprint(otherID), models.PaymentRequest{}), nil)
+
+	rcpt, err := usecases.GetReceipt(other, fmt.Sprint(otherID))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rcpt.Lot, "UC Scoped")
+	assert.Equal(t, rcpt.Number, uint(1))
+	assert.Equal(t, rcpt.Amount, int64(1200))
+}
+
+func TestLotPlateFormats(t *testing.T) {
+	_, err := usecases.CreateLot("", models.LotRequest{Name: "UC Unknown Formats", PlateFormats: []string{"xx-unknown"}})
+	assert.Equal(t, errors.Is(err, utils.ErrLotNotValid), true)
+
+	argentine := lotCaller(t, "", models.LotRequest{
+		Name:         "UC Buenos Aires",
+		PlateFormats: []string{plates.ARMercosul, plates.AROld},
+	}, models.RoleOperator)
+
+	id, err := usecases.MakeReservation(argentine, models.ParkingRequest{Plate: "ab123cd"})
+	assert.Equal(t, err, nil)
+	parking, _ := storage.GetParking(argentine.Lot.ID, id)
+	assert.Equal(t, parking.Plate, "AB 123 CD")
+
+	// Each lot its own formats
+	_, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "ab123cd"})
+	assert.Equal(t, errors.Is(err, utils.ErrPlateNotValid), true)
+
+	_, err = usecases.MakeReservation(argentine, models.ParkingRequest{Plate: "ABC-1234"})
+	assert.Equal(t, errors.Is(err, utils.ErrPlateNotValid), true)
+	assert.Equal(t, err.(models.Invalid).Fields[0].Message, "must be a plate, formats: AB 123 CD, ABC 123")
+
+	// Readings are corrected to the lot's formats
+	image, _ := ioutil.ReadFile("../assets/download2.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(image): recognition.Certain("CD 456 EF"),
+	}))
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	result, err := usecases.CheckinImage(argentine, image, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Plate, "CD 456 EF")
+	assert.Greater(t, result.ID, uint(0))
+}
+
+func TestAdminHistory(t *testing.T) {
+	admin := lotCaller(t, "UC Chain", models.LotRequest{Name: "UC North"}, models.RoleAdmin)
+	south := lotCaller(t, "UC Chain", models.LotRequest{Name: "UC South"}, models.RoleOperator)
+
+	north, err := usecases.MakeReservation(admin, models.ParkingRequest{Plate: "ADM-1234"})
+	assert.Equal(t, err, nil)
+	southID, err := usecases.MakeReservation(south, models.ParkingRequest{Plate: "ADM-1234"})
+	assert.Equal(t, err, nil)
+
+	// Not in the company, not listed
+	_, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "ADM-1234"})
+	assert.Equal(t, err, nil)
+
+	_, err = usecases.GetReservations(south, models.ParkingRequest{Plate: "ADM-1234"}, true)
+	assert.Equal(t, err, utils.ErrForbidden)
+
+	history, err := usecases.GetReservations(admin, models.ParkingRequest{Plate: "ADM-1234"}, false)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(history), 1)
+	assert.Equal(t, history[0].ID, north)
+	assert.Equal(t, history[0].Lot, "")
+
+	history, err = usecases.GetReservations(admin, models.ParkingRequest{Plate: "ADM-1234"}, true)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(history), 2)
+	assert.Equal(t, history[0].ID, north)
+	assert.Equal(t, history[0].Lot, "UC North")
+	assert.Equal(t, history[1].ID, southID)
+	assert.Equal(t, history[1].Lot, "UC South")
+}
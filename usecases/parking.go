@@ -1,32 +1,62 @@
 package usecases
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"br.com.mlabs/config"
+	"br.com.mlabs/images"
 	"br.com.mlabs/models"
+	"br.com.mlabs/receipt"
 	"br.com.mlabs/storage"
+	"br.com.mlabs/ticket"
 	"br.com.mlabs/utils"
 )
 
-// MakeReservation asserts business logic
-func MakeReservation(request models.ParkingRequest) (uint, error) {
-	if !models.Validate(request) {
-		return 0, utils.ErrPlateNotValid
+// MakeReservation asserts business logic, the plate is stored in its canonical form
+func MakeReservation(caller models.Caller, request models.ParkingRequest) (uint, error) {
+	request.Plate = caller.Lot.NormalizePlate(request.Plate)
+	if err := caller.Lot.Verify(models.ParkingRequest{Plate: request.Plate}, utils.ErrPlateNotValid); err != nil {
+		return 0, err
+	}
+	if err := caller.Lot.Verify(request, utils.ErrCategoryNotValid); err != nil {
+		return 0, err
 	}
 
-	return storage.ParkingReservation(request)
+	return storage.ParkingReservation(caller.Lot.ID, request, config.LotCapacity(caller.Lot), config.NoShowGrace())
 }
 
-// GetReservations gets all the reservations under a plante
-func GetReservations(request models.ParkingRequest) (models.ParkingHistory, error) {
-	if !models.Validate(request) {
+// GetReservations gets all the reservations under a plate in the caller's lot, before and after it was converted to Mercosul.
+// Admins may ask for every lot they see, each entry then names its lot
+func GetReservations(caller models.Caller, request models.ParkingRequest, allLots bool) (models.ParkingHistory, error) {
+	request.Plate = caller.Lot.NormalizePlate(request.Plate)
+	if !caller.Lot.Validate(request) {
 		return nil, utils.ErrPlateNotValid
 	}
 
-	parkingPayments, err := storage.ParkingHistory(request)
+	lotIDs := []uint{caller.Lot.ID}
+	names := map[uint]string{}
+	if allLots {
+		if !caller.Admin() {
+			return nil, utils.ErrForbidden
+		}
+
+		lots, err := seenLots(caller)
+		if err != nil {
+			return nil, err
+		}
+		lotIDs = nil
+		for _, lot := range lots {
+			lotIDs = append(lotIDs, lot.ID)
+			names[lot.ID] = lot.Name
+		}
+	}
+
+	parkingPayments, err := storage.ParkingHistory(lotIDs, request)
 	if err != nil {
 		return nil, err
 	}
@@ -43,10 +73,12 @@ func GetReservations(request models.ParkingRequest) (models.ParkingHistory, erro
 		timeDiff := fmt.Sprintf("%.0f minutes", parking.Checkout.Sub(parking.Checkin).Minutes())
 
 		entry := models.ParkingHistoryEntry{
-			ID:   parking.ID,
-			Left: left,
-			Paid: parking.Paid,
-			Time: timeDiff,
+			ID:       parking.ID,
+			Lot:      names[parking.LotID],
+			Category: parking.Category,
+			Left:     left,
+			Paid:     parking.Paid,
+			Time:     timeDiff,
 		}
 		history = append(history, entry)
 	}
@@ -54,26 +86,179 @@ func GetReservations(request models.ParkingRequest) (models.ParkingHistory, erro
 	return history, nil
 }
 
-// Pay sets the payment as true
-func Pay(idVar string) error {
-	id, err := strconv.ParseUint(idVar, 10, 64)
+// GetTicket gets the printable ticket of a parking space
+func GetTicket(caller models.Caller, idVar string) (ticket.Ticket, error) {
+	id, err := parseID(idVar)
+	if err != nil {
+		return ticket.Ticket{}, err
+	}
+
+	parking, err := storage.GetParking(caller.Lot.ID, id)
 	if err != nil {
-		return utils.ErrIDNotValid
+		return ticket.Ticket{}, err
 	}
 
-	return storage.Pay(uint(id))
+	return ticket.Ticket{
+		Lot:     caller.Lot.Name,
+		ID:      parking.ID,
+		Plate:   parking.Plate,
+		Checkin: parking.Checkin,
+		Token:   ticket.Token(parking.ID),
+	}, nil
 }
 
-// Checkout checks out a parking space
-func Checkout(idVar string) error {
-	id64, err := strconv.ParseUint(idVar, 10, 64)
+// Pay charges the stay following the tariff, minus any prepaid booking
+func Pay(caller models.Caller, idVar string, request models.PaymentRequest) error {
+	id, err := parseID(idVar)
 	if err != nil {
-		return utils.ErrIDNotValid
+		return err
 	}
 
-	id := uint(id64)
+	if err := caller.Lot.Verify(request, utils.ErrPaymentNotValid); err != nil {
+		return err
+	}
+
+	parking, err := storage.GetParking(caller.Lot.ID, id)
+	if err != nil {
+		return err
+	}
 
-	paid, err := storage.IsPaid(id)
+	payment, err := charge(caller.Lot, parking, time.Now(), request.Method)
+	if err != nil {
+		return err
+	}
+
+	return storage.Pay(caller.Lot.ID, id, payment)
+}
+
+// charge prices the stay up to the given time following the lot's tariff for its category, minus any prepaid booking
+func charge(lot models.Lot, parking models.Parking, at time.Time, method string) (models.Payment, error) {
+	if method == "" {
+		method = models.PaymentCash
+	}
+
+	lines := config.LotTariff(lot, parking.Category).Charge(at.Sub(parking.Checkin))
+	amount := models.Total(lines) - parking.Prepaid
+	if amount < 0 {
+		amount = 0
+	}
+
+	linesJSON, err := json.Marshal(lines)
+	if err != nil {
+		return models.Payment{}, utils.ErrInternalServer
+	}
+
+	return models.Payment{
+		Amount: amount,
+		Method: method,
+		Lines:  string(linesJSON),
+	}, nil
+}
+
+// GetReceipt gets the receipt of a paid parking space, it can be printed again at any time
+func GetReceipt(caller models.Caller, idVar string) (receipt.Receipt, error) {
+	id, err := parseID(idVar)
+	if err != nil {
+		return receipt.Receipt{}, err
+	}
+
+	if _, err := storage.GetParking(caller.Lot.ID, id); err != nil {
+		return receipt.Receipt{}, err
+	}
+
+	rcpt, err := storage.GetReceipt(caller.Lot.ID, id)
+	if err != nil {
+		return receipt.Receipt{}, err
+	}
+
+	var lines []models.TariffLine
+	if err := json.Unmarshal([]byte(rcpt.Payment.Lines), &lines); err != nil {
+		return receipt.Receipt{}, utils.ErrInternalServer
+	}
+
+	parking := rcpt.Payment.Parking
+	return receipt.Receipt{
+		Lot:      caller.Lot.Name,
+		Number:   rcpt.Number,
+		Plate:    parking.Plate,
+		Checkin:  parking.Checkin,
+		Checkout: parking.Checkout,
+		PaidAt:   rcpt.Payment.CreatedAt,
+		Lines:    lines,
+		Prepaid:  parking.Prepaid,
+		Amount:   rcpt.Payment.Amount,
+		Method:   rcpt.Payment.Method,
+	}, nil
+}
+
+// Checkout checks out a parking space, stays within the grace period leave without paying
+func Checkout(caller models.Caller, idVar string) error {
+	id, err := parseID(idVar)
+	if err != nil {
+		return err
+	}
+
+	return checkout(caller.Lot, id)
+}
+
+// Exit checks out the open ticket of a plate, as seen by an exit camera. When it must be paid first,
+// the gate stays closed and the result has the amount due, unless a prepaid booking covers it all
+func Exit(caller models.Caller, plate string) (models.ExitResult, error) {
+	plate = caller.Lot.NormalizePlate(plate)
+	if !caller.Lot.Validate(models.ParkingRequest{Plate: plate}) {
+		return models.ExitResult{}, utils.ErrPlateNotValid
+	}
+
+	parking, err := storage.OpenParking(caller.Lot.ID, plate)
+	if err != nil {
+		return models.ExitResult{}, err
+	}
+
+	result := models.ExitResult{ID: parking.ID, Plate: parking.Plate}
+	err = checkout(caller.Lot, parking.ID)
+	if err == utils.ErrPayFirst {
+		payment, err := charge(caller.Lot, parking, time.Now(), "")
+		if err != nil {
+			return result, err
+		}
+		if payment.Amount > 0 {
+			result.AmountDue = payment.Amount
+			return result, nil
+		}
+
+		// The prepaid booking covers the stay, nothing is due and it is recorded as paid
+		if err := storage.Pay(caller.Lot.ID, parking.ID, payment); err != nil && err != utils.ErrAlreadyPaid {
+			return result, err
+		}
+		err = checkout(caller.Lot, parking.ID)
+	}
+	if err != nil && err != utils.ErrAlreadyCheckedOut {
+		return result, err
+	}
+
+	result.Open = true
+	return result, nil
+}
+
+// ExitImage reads the plate of an exit camera image and checks it out as Exit does.
+// Exits have no review queue, a reading that is not valid or not confident enough fails so the attendant takes over
+func ExitImage(caller models.Caller, data []byte, camera string) (models.ExitResult, error) {
+	recognized, err := RecognizeImage(caller, data, debugBase(images.Key(data)), camera)
+	if err != nil {
+		return models.ExitResult{}, err
+	}
+	if !caller.Lot.Validate(models.ParkingRequest{Plate: recognized.Plate}) || recognized.Confidence < config.OCRMinConfidence() {
+		return models.ExitResult{Recognition: &recognized}, utils.ErrImageRecognition
+	}
+
+	result, err := Exit(caller, recognized.Plate)
+	result.Recognition = &recognized
+	return result, err
+}
+
+// checkout lets a paid parking of the lot, or one within the grace period, out
+func checkout(lot models.Lot, id uint) error {
+	paid, err := storage.IsPaid(lot.ID, id)
 	if err != nil {
 		if !errors.Is(err, utils.ErrNotFound) {
 			return utils.ErrInternalServer
@@ -81,8 +266,28 @@ func Checkout(idVar string) error {
 		return err
 	}
 	if !paid {
-		return utils.ErrPayFirst
+		parking, err := storage.GetParking(lot.ID, id)
+		if err != nil {
+			return err
+		}
+		if time.Since(parking.Checkin) >= config.LotTariff(lot, parking.Category).Grace {
+			return utils.ErrPayFirst
+		}
+	}
+
+	return storage.Checkout(lot.ID, id)
+}
+
+// parseID accepts either a numeric ticket id or a signed ticket token
+func parseID(idVar string) (uint, error) {
+	if strings.Contains(idVar, ".") {
+		return ticket.ParseToken(idVar)
+	}
+
+	id, err := strconv.ParseUint(idVar, 10, 64)
+	if err != nil {
+		return 0, utils.ErrIDNotValid
 	}
 
-	return storage.Checkout(id)
+	return uint(id), nil
 }
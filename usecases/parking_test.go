@@ -1,12 +1,21 @@
 package usecases_test
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"br.com.mlabs/config"
 	"br.com.mlabs/models"
+	"br.com.mlabs/plates"
+	"br.com.mlabs/recognition"
 	"br.com.mlabs/storage"
+	"br.com.mlabs/ticket"
 	"br.com.mlabs/usecases"
 	"br.com.mlabs/utils"
 	"github.com/stretchr/testify/assert"
@@ -15,58 +24,76 @@ import (
 
 var tx *gorm.DB
 
+// caller works in the default lot, like requests without an API key
+var caller models.Caller
+
 func TestMain(t *testing.M) {
 	storage.ConnectTest()
 	tx = storage.StartTest()
+	caller, _ = usecases.DefaultCaller()
 
 	t.Run()
 
 	tx.Exec("TRUNCATE parkings CASCADE;")
+	tx.Exec("TRUNCATE bookings;")
+	tx.Exec("TRUNCATE gate_events;")
+	tx.Exec("TRUNCATE reviews;")
 	tx.Exec("ALTER SEQUENCE parkings_id_seq RESTART WITH 1")
 	tx.Exec("ALTER SEQUENCE payments_id_seq RESTART WITH 1")
+	tx.Exec("ALTER SEQUENCE bookings_id_seq RESTART WITH 1")
+	tx.Exec("ALTER SEQUENCE receipts_id_seq RESTART WITH 1")
+	tx.Exec("UPDATE receipt_sequences SET last = 0")
+	tx.Exec("DELETE FROM users WHERE lot_id IN (SELECT id FROM lots WHERE name LIKE 'UC %')")
+	tx.Exec("DELETE FROM receipt_sequences WHERE id IN (SELECT id FROM lots WHERE name LIKE 'UC %')")
+	tx.Exec("DELETE FROM lot_categories WHERE lot_id IN (SELECT id FROM lots WHERE name LIKE 'UC %')")
+	tx.Exec("DELETE FROM lots WHERE name LIKE 'UC %'")
+	tx.Exec("DELETE FROM companies WHERE name LIKE 'UC %'")
 }
 
 func TestPay(t *testing.T) {
-	assert.Equal(t, usecases.Pay("notvalid"), utils.ErrIDNotValid)
-	assert.Equal(t, usecases.Pay("-1"), utils.ErrIDNotValid)
-	assert.Equal(t, usecases.Pay("1"), utils.ErrNotFound)
+	assert.Equal(t, usecases.Pay(caller, "notvalid", models.PaymentRequest{}), utils.ErrIDNotValid)
+	assert.Equal(t, usecases.Pay(caller, "-1", models.PaymentRequest{}), utils.ErrIDNotValid)
+	assert.Equal(t, usecases.Pay(caller, "1", models.PaymentRequest{}), utils.ErrNotFound)
 
 	// Test for happy path
 	parking := models.Parking{
+		LotID:   caller.Lot.ID,
 		Plate:   "ABC-1234",
 		Checkin: time.Now(),
 	}
 	tx.Create(&parking)
 
-	assert.Equal(t, usecases.Pay(fmt.Sprint(parking.ID)), nil)
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprint(parking.ID), models.PaymentRequest{}), nil)
 
-	assert.Equal(t, usecases.Pay(fmt.Sprint(parking.ID)), utils.ErrAlreadyPaid)
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprint(parking.ID), models.PaymentRequest{}), utils.ErrAlreadyPaid)
 }
 
 func TestCheckout(t *testing.T) {
-	assert.Equal(t, usecases.Checkout("notvalid"), utils.ErrIDNotValid)
-	assert.Equal(t, usecases.Checkout("-1"), utils.ErrIDNotValid)
-	assert.Equal(t, usecases.Checkout("1000"), utils.ErrNotFound)
+	assert.Equal(t, usecases.Checkout(caller, "notvalid"), utils.ErrIDNotValid)
+	assert.Equal(t, usecases.Checkout(caller, "-1"), utils.ErrIDNotValid)
+	assert.Equal(t, usecases.Checkout(caller, "1000"), utils.ErrNotFound)
 
 	// Test for happy path
 	parking := models.Parking{
+		LotID:   caller.Lot.ID,
 		Plate:   "ABC-1234",
 		Checkin: time.Now(),
 	}
 	tx.Create(&parking)
 
-	assert.Equal(t, usecases.Checkout(fmt.Sprint(parking.ID)), utils.ErrPayFirst)
+	assert.Equal(t, usecases.Checkout(caller, fmt.Sprint(parking.ID)), utils.ErrPayFirst)
 
 	payment := models.Payment{
+		LotID:     caller.Lot.ID,
 		Paid:      true,
 		ParkingID: parking.ID,
 	}
 
 	tx.Create(&payment)
 
-	assert.Equal(t, usecases.Checkout(fmt.Sprint(parking.ID)), nil)
+	assert.Equal(t, usecases.Checkout(caller, fmt.Sprint(parking.ID)), nil)
 
-	assert.Equal(t, usecases.Checkout(fmt.Sprint(parking.ID)), utils.ErrAlreadyCheckedOut)
+	assert.Equal(t, usecases.Checkout(caller, fmt.Sprint(parking.ID)), utils.ErrAlreadyCheckedOut)
 }
 
 func TestMakeReservation(t *testing.T) {
@@ -74,13 +101,13 @@ func TestMakeReservation(t *testing.T) {
 		Plate: "ABC-1234",
 	}
 
-	id, err := usecases.MakeReservation(parking)
+	id, err := usecases.MakeReservation(caller, parking)
 	assert.Equal(t, err, nil)
 	assert.Greater(t, id, uint(0))
 
 	parking.Plate = "ab"
-	id, err = usecases.MakeReservation(parking)
-	assert.Equal(t, err, utils.ErrPlateNotValid)
+	id, err = usecases.MakeReservation(caller, parking)
+	assert.Equal(t, errors.Is(err, utils.ErrPlateNotValid), true)
 	assert.Equal(t, id, uint(0))
 }
 
@@ -89,23 +116,24 @@ func TestGetReservations(t *testing.T) {
 		Plate: "ABC-12555",
 	}
 
-	history, err := usecases.GetReservations(request)
+	history, err := usecases.GetReservations(caller, request, false)
 	assert.Equal(t, err, utils.ErrPlateNotValid)
 	assert.Equal(t, history, models.ParkingHistory(nil))
 
 	request.Plate = "ABD-9999"
-	history, err = usecases.GetReservations(request)
+	history, err = usecases.GetReservations(caller, request, false)
 	assert.Equal(t, err, utils.ErrNotFound)
 	assert.Equal(t, history, models.ParkingHistory(nil))
 
 	// Happy path, history with no payment
 	parking := models.Parking{
+		LotID:   caller.Lot.ID,
 		Plate:   request.Plate,
 		Checkin: time.Now(),
 	}
 	tx.Create(&parking)
 
-	history, err = usecases.GetReservations(request)
+	history, err = usecases.GetReservations(caller, request, false)
 	assert.Equal(t, history, models.ParkingHistory{
 		{
 			ID:   parking.ID,
@@ -118,12 +146,13 @@ func TestGetReservations(t *testing.T) {
 
 	// Happy path, history with paid = true
 	pay := models.Payment{
+		LotID:     caller.Lot.ID,
 		Paid:      true,
 		ParkingID: parking.ID,
 	}
 	tx.Create(&pay)
 
-	history, err = usecases.GetReservations(request)
+	history, err = usecases.GetReservations(caller, request, false)
 	assert.Equal(t, history, models.ParkingHistory{
 		{
 			ID:   parking.ID,
@@ -136,12 +165,13 @@ func TestGetReservations(t *testing.T) {
 
 	// Happy path, history with two entries
 	parking2 := models.Parking{
+		LotID:   caller.Lot.ID,
 		Plate:   request.Plate,
 		Checkin: time.Now(),
 	}
 	tx.Create(&parking2)
 
-	history, err = usecases.GetReservations(request)
+	history, err = usecases.GetReservations(caller, request, false)
 	assert.Equal(t, history, models.ParkingHistory{
 		{
 			ID:   parking.ID,
@@ -158,3 +188,366 @@ func TestGetReservations(t *testing.T) {
 	})
 	assert.Equal(t, err, nil)
 }
+
+func TestPayWithToken(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "TKN-1234",
+		Checkin: time.Now(),
+	}
+	tx.Create(&parking)
+
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprintf("%d.forged", parking.ID), models.PaymentRequest{}), utils.ErrTicketNotValid)
+	assert.Equal(t, usecases.Pay(caller, ticket.Token(parking.ID), models.PaymentRequest{}), nil)
+	assert.Equal(t, usecases.Checkout(caller, ticket.Token(parking.ID)), nil)
+}
+
+func TestGetTicket(t *testing.T) {
+	_, err := usecases.GetTicket(caller, "notvalid")
+	assert.Equal(t, err, utils.ErrIDNotValid)
+
+	_, err = usecases.GetTicket(caller, "9999")
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "TKN-5678",
+		Checkin: time.Now(),
+	}
+	tx.Create(&parking)
+
+	tkt, err := usecases.GetTicket(caller, fmt.Sprint(parking.ID))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, tkt.Plate, "TKN-5678")
+	assert.Equal(t, tkt.Token, ticket.Token(parking.ID))
+}
+
+func TestPayMethod(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "PAY-1234",
+		Checkin: time.Now(),
+	}
+	tx.Create(&parking)
+
+	assert.Equal(t, errors.Is(usecases.Pay(caller, fmt.Sprint(parking.ID), models.PaymentRequest{Method: "cheque"}), utils.ErrPaymentNotValid), true)
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprint(parking.ID), models.PaymentRequest{Method: models.PaymentPix}), nil)
+
+	var payment models.Payment
+	tx.Where("parking_id = ?", parking.ID).First(&payment)
+	assert.Equal(t, payment.Method, models.PaymentPix)
+	assert.Equal(t, payment.Amount, config.Tariff().FirstHour)
+}
+
+func TestGetReceipt(t *testing.T) {
+	_, err := usecases.GetReceipt(caller, "notvalid")
+	assert.Equal(t, err, utils.ErrIDNotValid)
+
+	_, err = usecases.GetReceipt(caller, "9999")
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "RCP-1234",
+		Checkin: time.Now().Add(-90 * time.Minute),
+		Prepaid: 300,
+	}
+	tx.Create(&parking)
+
+	_, err = usecases.GetReceipt(caller, fmt.Sprint(parking.ID))
+	assert.Equal(t, err, utils.ErrNotPaid)
+
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprint(parking.ID), models.PaymentRequest{Method: models.PaymentDebit}), nil)
+
+	rcpt, err := usecases.GetReceipt(caller, fmt.Sprint(parking.ID))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rcpt.Plate, "RCP-1234")
+	assert.Equal(t, rcpt.Method, models.PaymentDebit)
+	assert.Equal(t, rcpt.Prepaid, int64(300))
+	assert.Equal(t, rcpt.Amount, models.Total(rcpt.Lines)-300)
+
+	// Receipt numbers have no gaps
+	parking2 := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "RCP-1234",
+		Checkin: time.Now(),
+	}
+	tx.Create(&parking2)
+	usecases.Pay(caller, fmt.Sprint(parking2.ID), models.PaymentRequest{})
+
+	rcpt2, err := usecases.GetReceipt(caller, ticket.Token(parking2.ID))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rcpt2.Number, rcpt.Number+1)
+}
+
+func TestPayConcurrent(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "CNC-1234",
+		Checkin: time.Now(),
+	}
+	tx.Create(&parking)
+
+	errs := make(chan error, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- usecases.Pay(caller, fmt.Sprint(parking.ID), models.PaymentRequest{})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	paid := 0
+	for err := range errs {
+		if err == nil {
+			paid++
+			continue
+		}
+		assert.Equal(t, err, utils.ErrAlreadyPaid)
+	}
+	assert.Equal(t, paid, 1)
+
+	var payments, receipts int64
+	tx.Model(&models.Payment{}).Where("parking_id = ?", parking.ID).Count(&payments)
+	assert.Equal(t, payments, int64(1))
+	tx.Model(&models.Receipt{}).Joins("JOIN payments ON payments.id = receipts.payment_id").Where("payments.parking_id = ?", parking.ID).Count(&receipts)
+	assert.Equal(t, receipts, int64(1))
+}
+
+func TestCheckoutConcurrent(t *testing.T) {
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "CNC-5678",
+		Checkin: time.Now(),
+	}
+	tx.Create(&parking)
+	tx.Create(&models.Payment{LotID: caller.Lot.ID, Paid: true, ParkingID: parking.ID})
+
+	errs := make(chan error, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- usecases.Checkout(caller, fmt.Sprint(parking.ID))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	checkedOut := 0
+	for err := range errs {
+		if err == nil {
+			checkedOut++
+			continue
+		}
+		assert.Equal(t, err, utils.ErrAlreadyCheckedOut)
+	}
+	assert.Equal(t, checkedOut, 1)
+}
+
+func TestExit(t *testing.T) {
+	_, err := usecases.Exit(caller, "EXT-12")
+	assert.Equal(t, err, utils.ErrPlateNotValid)
+	_, err = usecases.Exit(caller, "EXT-0000")
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "EXT-1234",
+		Checkin: time.Now().Add(-90 * time.Minute),
+	}
+	tx.Create(&parking)
+
+	// Two hours are due, the gate stays closed
+	result, err := usecases.Exit(caller, "EXT-1234")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.ID, parking.ID)
+	assert.Equal(t, result.Open, false)
+	assert.Equal(t, result.AmountDue, config.Tariff().FirstHour+config.Tariff().AdditionalHour)
+
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprint(parking.ID), models.PaymentRequest{}), nil)
+
+	result, err = usecases.Exit(caller, "EXT-1234")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Open, true)
+	assert.Equal(t, result.AmountDue, int64(0))
+
+	// Checked out, nothing is open anymore
+	_, err = usecases.Exit(caller, "EXT-1234")
+	assert.Equal(t, err, utils.ErrNotFound)
+}
+
+func TestExitPrepaid(t *testing.T) {
+	// The booking paid more than the two hours due
+	parking := models.Parking{
+		LotID:   caller.Lot.ID,
+		Plate:   "EXT-2468",
+		Checkin: time.Now().Add(-90 * time.Minute),
+		Prepaid: config.Tariff().FirstHour + config.Tariff().AdditionalHour + 100,
+	}
+	tx.Create(&parking)
+
+	result, err := usecases.Exit(caller, "EXT-2468")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.ID, parking.ID)
+	assert.Equal(t, result.Open, true)
+	assert.Equal(t, result.AmountDue, int64(0))
+
+	// Recorded as a zero payment, with its receipt
+	rcpt, err := usecases.GetReceipt(caller, fmt.Sprint(parking.ID))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, rcpt.Amount, int64(0))
+	assert.NotEqual(t, rcpt.Checkout, (*time.Time)(nil))
+}
+
+func TestExitSeveralOpen(t *testing.T) {
+	tx.Create(&models.Parking{LotID: caller.Lot.ID, Plate: "EXT-5678", Checkin: time.Now()})
+	tx.Create(&models.Parking{LotID: caller.Lot.ID, Plate: "EXT-5678", Checkin: time.Now()})
+
+	_, err := usecases.Exit(caller, "EXT-5678")
+	assert.Equal(t, err, utils.ErrSeveralOpen)
+}
+
+func TestExitImage(t *testing.T) {
+	image, _ := ioutil.ReadFile("../assets/download2.jpg")
+	blurry, _ := ioutil.ReadFile("../assets/download.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(image):  recognition.Certain("EXT3R52"),
+		recognition.Hash(blurry): {Text: "EXT-4321", Confidence: 40},
+	}))
+	defer usecases.SetRecognizer(nil)
+
+	parking := models.Parking{LotID: caller.Lot.ID, Plate: "EXT3R52", Checkin: time.Now()}
+	tx.Create(&parking)
+	tx.Create(&models.Payment{LotID: caller.Lot.ID, Paid: true, ParkingID: parking.ID})
+
+	result, err := usecases.ExitImage(caller, image, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.ID, parking.ID)
+	assert.Equal(t, result.Open, true)
+	assert.Equal(t, result.Recognition.Plate, "EXT3R52")
+
+	// Not sure enough to open the gate
+	result, err = usecases.ExitImage(caller, blurry, "")
+	assert.Equal(t, err, utils.ErrImageRecognition)
+	assert.Equal(t, result.Recognition.Plate, "EXT-4321")
+}
+
+func TestPlateNormalized(t *testing.T) {
+	id, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: " nrm 1234"})
+	assert.Equal(t, err, nil)
+
+	parking, _ := storage.GetParking(caller.Lot.ID, id)
+	assert.Equal(t, parking.Plate, "NRM-1234")
+
+	// The same car after it got its Mercosul plate
+	converted, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "nrm1c34"})
+	assert.Equal(t, err, nil)
+
+	for _, plate := range []string{"NRM-1234", "nrm1234", "NRM1C34"} {
+		history, err := usecases.GetReservations(caller, models.ParkingRequest{Plate: plate}, false)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(history), 2, plate)
+		assert.Equal(t, history[0].ID+history[1].ID, id+converted)
+	}
+
+	_, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "nrm 123"})
+	assert.Equal(t, errors.Is(err, utils.ErrPlateNotValid), true)
+}
+
+func TestPlateFormatStored(t *testing.T) {
+	id, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "FMT1D23"})
+	assert.Equal(t, err, nil)
+	parking, _ := storage.GetParking(caller.Lot.ID, id)
+	assert.Equal(t, parking.PlateFormat, plates.BRMercosul)
+	assert.Equal(t, parking.Country, "BR")
+
+	// Argentine plates once the lot accepts them
+	_, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "fm 123 ab"})
+	assert.Equal(t, errors.Is(err, utils.ErrPlateNotValid), true)
+
+	plates.Enable(append(plates.Enabled(), mustFormat(t, plates.ARMercosul)))
+	defer plates.Enable([]plates.Format{mustFormat(t, plates.BROld), mustFormat(t, plates.BRMercosul)})
+
+	id, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "fm 123 ab"})
+	assert.Equal(t, err, nil)
+	parking, _ = storage.GetParking(caller.Lot.ID, id)
+	assert.Equal(t, parking.Plate, "FM 123 AB")
+	assert.Equal(t, parking.PlateFormat, plates.ARMercosul)
+	assert.Equal(t, parking.Country, "AR")
+}
+
+func TestCategory(t *testing.T) {
+	id, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "CAT-1234"})
+	assert.Equal(t, err, nil)
+	parking, _ := storage.GetParking(caller.Lot.ID, id)
+	assert.Equal(t, parking.Category, models.CategoryCar)
+
+	id, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "CAT-5678", Category: models.CategoryVan})
+	assert.Equal(t, err, nil)
+	parking, _ = storage.GetParking(caller.Lot.ID, id)
+	assert.Equal(t, parking.Category, models.CategoryVan)
+
+	_, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "CAT-9012", Category: "bicycle"})
+	assert.Equal(t, errors.Is(err, utils.ErrCategoryNotValid), true)
+
+	// Argentine Mercosul motorcycle plates are only issued to motorcycles
+	plates.Enable(append(plates.Enabled(), mustFormat(t, plates.ARMercosulMoto)))
+	defer plates.Enable([]plates.Format{mustFormat(t, plates.BROld), mustFormat(t, plates.BRMercosul)})
+
+	id, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "c123cat"})
+	assert.Equal(t, err, nil)
+	parking, _ = storage.GetParking(caller.Lot.ID, id)
+	assert.Equal(t, parking.Plate, "C 123 CAT")
+	assert.Equal(t, parking.Category, models.CategoryMotorcycle)
+
+	history, err := usecases.GetReservations(caller, models.ParkingRequest{Plate: "CAT-5678"}, false)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, history[0].Category, models.CategoryVan)
+}
+
+func TestCategoryCapacity(t *testing.T) {
+	// A single truck space left, cars still come in
+	var trucks int64
+	tx.Model(&models.Parking{}).Where("checkout IS NULL AND category = ?", models.CategoryTruck).Count(&trucks)
+	os.Setenv("PARKING_CAPACITY_TRUCK", strconv.Itoa(int(trucks)+1))
+	defer os.Unsetenv("PARKING_CAPACITY_TRUCK")
+
+	_, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "TRK-1234", Category: models.CategoryTruck})
+	assert.Equal(t, err, nil)
+	_, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "TRK-5678", Category: models.CategoryTruck})
+	assert.Equal(t, err, utils.ErrLotFull)
+	_, err = usecases.MakeReservation(caller, models.ParkingRequest{Plate: "TRK-9012"})
+	assert.Equal(t, err, nil)
+}
+
+func TestCategoryTariff(t *testing.T) {
+	os.Setenv("TARIFF_TRUCK_FIRST_HOUR", "3000")
+	defer os.Unsetenv("TARIFF_TRUCK_FIRST_HOUR")
+
+	truck := models.Parking{LotID: caller.Lot.ID, Plate: "TRF-1234", Category: models.CategoryTruck, Checkin: time.Now()}
+	tx.Create(&truck)
+	car := models.Parking{LotID: caller.Lot.ID, Plate: "TRF-5678", Category: models.CategoryCar, Checkin: time.Now()}
+	tx.Create(&car)
+
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprint(truck.ID), models.PaymentRequest{}), nil)
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprint(car.ID), models.PaymentRequest{}), nil)
+
+	var truckPayment, carPayment models.Payment
+	tx.Where("parking_id = ?", truck.ID).First(&truckPayment)
+	assert.Equal(t, truckPayment.Amount, int64(3000))
+	tx.Where("parking_id = ?", car.ID).First(&carPayment)
+	assert.Equal(t, carPayment.Amount, config.Tariff().FirstHour)
+}
+
+func mustFormat(t *testing.T, id string) plates.Format {
+	format, ok := plates.Get(id)
+	if !ok {
+		t.Fatalf("unknown format %s", id)
+	}
+	return format
+}
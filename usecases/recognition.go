@@ -1,24 +1,275 @@
 package usecases
 
 import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
 	"unicode"
 
+	"br.com.mlabs/config"
+	"br.com.mlabs/imaging"
 	"br.com.mlabs/models"
+	"br.com.mlabs/recognition"
 	"br.com.mlabs/utils"
-	"github.com/otiai10/gosseract"
+	"github.com/sirupsen/logrus"
 )
 
-// Recognize gets words from an image
-func Recognize(path string) (models.ParkingRequest, error) {
-	client := gosseract.NewClient()
-	defer client.Close()
+// plateCandidates is how many regions are read before falling back to the whole image
+const plateCandidates = 5
 
-	client.SetImage(path)
-	text, err := client.Text()
+// recognizer reads the plates, images are not read until one is set
+var recognizer recognition.PlateRecognizer
+
+// SetRecognizer chooses the OCR engine used to read plates, nil stops reading them
+func SetRecognizer(r recognition.PlateRecognizer) {
+	recognizer = r
+}
+
+// region is a part of the upload sent to OCR
+type region struct {
+	box  image.Rectangle
+	data []byte
+	name string
+}
+
+// maxAlternatives is how many other readings are returned with the best one
+const maxAlternatives = 5
+
+// reading is what was read from a region, fitted to a plate layout
+type reading struct {
+	candidate  models.Candidate
+	read       string
+	corrected  bool
+	characters []models.Character
+}
+
+// Recognize finds the regions of an image file shaped like a plate and reads each one, valid plates first and then the most confident wins.
+// Look-alike characters are corrected to the plate layouts of the caller's lot before validating, each swapped character halves its share of the confidence.
+// The whole image is read last, so a plate filling the frame is still found, and the best text is returned when nothing is valid.
+func Recognize(caller models.Caller, path string, camera string) (models.Recognition, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return models.ParkingRequest{}, utils.ErrImageRecognition
+		return models.Recognition{}, utils.ErrImageRecognition
+	}
+
+	return RecognizeImage(caller, data, strings.TrimSuffix(path, filepath.Ext(path)), camera)
+}
+
+// RecognizeImage is Recognize for an image in memory, in debug mode its stages are saved as base.1-gray.png and so on
+func RecognizeImage(caller models.Caller, data []byte, base string, camera string) (models.Recognition, error) {
+	var readings []reading
+	for _, r := range readRegions(caller.Lot, data, base, camera) {
+		readings = append(readings, r...)
+	}
+
+	if len(readings) == 0 {
+		return models.Recognition{}, utils.ErrImageRecognition
+	}
+
+	return bestReading(sortReadings(readings)), nil
+}
+
+// validPlates finds every valid plate in the image, for cameras covering more than one lane.
+// Each region gives its best reading, a plate found in several regions keeps the most confident, and plates are returned left to right.
+func validPlates(lot models.Lot, regions [][]reading) []models.Recognition {
+	var res []models.Recognition
+	found := map[string]int{}
+	for _, readings := range regions {
+		best := bestReading(sortReadings(readings))
+		if !lot.Validate(models.ParkingRequest{Plate: best.Plate}) {
+			continue
+		}
+
+		if i, ok := found[best.Plate]; ok {
+			if best.Confidence > res[i].Confidence {
+				res[i] = best
+			}
+			continue
+		}
+		found[best.Plate] = len(res)
+		res = append(res, best)
+	}
+
+	sort.SliceStable(res, func(i int, j int) bool {
+		return res[i].Box.X < res[j].Box.X
+	})
+
+	return res
+}
+
+// readRegions reads each region of the image in the plate formats of the lot, regions the engine could not read are left out
+func readRegions(lot models.Lot, data []byte, base string, camera string) [][]reading {
+	if recognizer == nil {
+		return nil
+	}
+
+	options := config.Camera(camera)
+
+	var res [][]reading
+	for _, r := range regions(base, data, options) {
+		processed, err := preprocess(r.name, r.data, options)
+		if err != nil {
+			continue
+		}
+
+		read, err := recognizer.Recognize(processed)
+		if err != nil {
+			continue
+		}
+
+		res = append(res, fitReadings(lot, read, models.Box{
+			X:      r.box.Min.X,
+			Y:      r.box.Min.Y,
+			Width:  r.box.Dx(),
+			Height: r.box.Dy(),
+		}))
 	}
 
+	return res
+}
+
+// sortReadings puts valid plates first, then the most confident
+func sortReadings(readings []reading) []reading {
+	sort.SliceStable(readings, func(i int, j int) bool {
+		a, b := readings[i].candidate, readings[j].candidate
+		if a.Valid != b.Valid {
+			return a.Valid
+		}
+		return a.Confidence > b.Confidence
+	})
+
+	return readings
+}
+
+// bestReading is the first of sorted readings, with the other plates read as alternatives
+func bestReading(readings []reading) models.Recognition {
+	best := readings[0]
+	if best.corrected {
+		logrus.Infof("Plate read as %s corrected to %s", best.read, best.candidate.Plate)
+	}
+
+	res := models.Recognition{
+		Plate:      best.candidate.Plate,
+		Read:       best.read,
+		Corrected:  best.corrected,
+		Confidence: best.candidate.Confidence,
+		Characters: best.characters,
+		Box:        best.candidate.Box,
+	}
+
+	seen := map[string]bool{best.candidate.Plate: true}
+	for _, r := range readings[1:] {
+		if seen[r.candidate.Plate] || len(res.Alternatives) == maxAlternatives {
+			continue
+		}
+		seen[r.candidate.Plate] = true
+		res.Alternatives = append(res.Alternatives, r.candidate)
+	}
+
+	return res
+}
+
+// fitReadings turns the engine's reading into the plates it may be, or into its text when it fits no layout
+func fitReadings(lot models.Lot, res models.Reading, box models.Box) []reading {
+	read := cleanText(res.Text)
+
+	var characters []models.Character
+	for _, c := range res.Characters {
+		if cleanText(c.Char) != "" {
+			characters = append(characters, c)
+		}
+	}
+
+	fits := recognition.FitsIn(lot.Formats(), read)
+	if len(fits) == 0 {
+		return []reading{{
+			candidate: models.Candidate{
+				Plate:      read,
+				Confidence: res.Confidence,
+				Valid:      lot.Validate(models.ParkingRequest{Plate: read}),
+				Box:        box,
+			},
+			read:       read,
+			characters: characters,
+		}}
+	}
+
+	var readings []reading
+	for _, fit := range fits {
+		readings = append(readings, reading{
+			candidate: models.Candidate{
+				Plate:      fit.Plate,
+				Confidence: res.Confidence * (1 - float64(fit.Swaps)/14),
+				Valid:      lot.Validate(models.ParkingRequest{Plate: fit.Plate}),
+				Box:        box,
+			},
+			read:       read,
+			corrected:  fit.Plate != read,
+			characters: characters,
+		})
+	}
+
+	return readings
+}
+
+// regions cuts the plate candidates out of the upload, followed by the whole image, in debug mode crops are saved as base.plate1.png and so on.
+// Cameras with a perspective already know where the plate is, so only the whole image is read.
+func regions(base string, data []byte, options imaging.Options) []region {
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return []region{{data: data, name: base}}
+	}
+
+	whole := region{box: img.Bounds().Sub(img.Bounds().Min), data: data, name: base}
+	if len(options.Perspective) == 4 {
+		if bounds, ok := perspectiveBounds(options.Perspective, whole.box); ok {
+			whole.box = bounds
+		}
+		return []region{whole}
+	}
+
+	var res []region
+	for i, candidate := range imaging.Locate(imaging.Grayscale(img), plateCandidates) {
+		box := imaging.Pad(candidate.Box, 0.15, whole.box)
+		crop, err := imaging.Encode(imaging.Crop(img, box))
+		if err != nil {
+			logrus.Warn(err.Error())
+			continue
+		}
+
+		name := fmt.Sprintf("%s.plate%d", base, i+1)
+		if config.OCRDebug() {
+			if err := ioutil.WriteFile(name+".png", crop, 0644); err != nil {
+				logrus.Warn(err.Error())
+			}
+		}
+
+		res = append(res, region{box: box, data: crop, name: name})
+	}
+
+	return append(res, whole)
+}
+
+// perspectiveBounds is the box around the plate corners set for a camera
+func perspectiveBounds(corners []imaging.Point, bounds image.Rectangle) (image.Rectangle, bool) {
+	var box image.Rectangle
+	for i, c := range corners {
+		p := image.Rect(int(c.X), int(c.Y), int(c.X)+1, int(c.Y)+1)
+		if i == 0 {
+			box = p
+		}
+		box = box.Union(p)
+	}
+	box = box.Intersect(bounds)
+
+	return box, !box.Empty()
+}
+
+// cleanText keeps the characters a plate may have
+func cleanText(text string) string {
 	cleanedText := ""
 	for _, s := range text {
 		if unicode.IsLetter(s) || unicode.IsDigit(s) || s == '-' {
@@ -26,7 +277,35 @@ func Recognize(path string) (models.ParkingRequest, error) {
 		}
 	}
 
-	return models.ParkingRequest{
-		Plate: cleanedText,
-	}, nil
+	return cleanedText
+}
+
+// preprocess cleans the image up for OCR, in debug mode each stage is saved as base.1-gray.png and so on
+func preprocess(base string, data []byte, options imaging.Options) ([]byte, error) {
+	if !options.Enabled() {
+		return data, nil
+	}
+
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var stage func(name string, img image.Image)
+	if config.OCRDebug() {
+		count := 0
+		stage = func(name string, img image.Image) {
+			count++
+			data, err := imaging.Encode(img)
+			if err != nil {
+				logrus.Warn(err.Error())
+				return
+			}
+			if err := ioutil.WriteFile(fmt.Sprintf("%s.%d-%s.png", base, count, name), data, 0644); err != nil {
+				logrus.Warn(err.Error())
+			}
+		}
+	}
+
+	return imaging.Encode(imaging.Process(img, options, stage))
 }
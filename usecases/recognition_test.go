@@ -1,26 +1,158 @@
 package usecases_test
 
 import (
+	"image"
+	"image/color"
+	"io/ioutil"
 	"testing"
 
+	"br.com.mlabs/imaging"
 	"br.com.mlabs/models"
+	"br.com.mlabs/recognition"
 	"br.com.mlabs/usecases"
 	"br.com.mlabs/utils"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestRecognize(t *testing.T) {
-	_, err := usecases.Recognize("notfound")
+func TestRecognizeWithoutEngine(t *testing.T) {
+	_, err := usecases.Recognize(caller, "../assets/download.jpg", "")
 
 	assert.Equal(t, err, utils.ErrImageRecognition)
+}
+
+func TestRecognizeFake(t *testing.T) {
+	image, _ := ioutil.ReadFile("../assets/download.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(image): {
+			Text:       " GTJ-6699\n",
+			Confidence: 84,
+			Characters: []models.Character{
+				{Char: "G", Confidence: 90},
+				{Char: "T", Confidence: 88},
+				{Char: "J", Confidence: 86},
+				{Char: "-", Confidence: 60},
+				{Char: "6", Confidence: 85},
+				{Char: "6", Confidence: 87},
+				{Char: "9", Confidence: 80},
+				{Char: "9", Confidence: 82},
+			},
+		},
+	}))
+	defer usecases.SetRecognizer(nil)
+
+	recognized, err := usecases.Recognize(caller, "../assets/download.jpg", "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, recognized.Plate, "GTJ-6699")
+	assert.Equal(t, recognized.Read, "GTJ-6699")
+	assert.Equal(t, recognized.Corrected, false)
+	assert.Equal(t, recognized.Confidence, 84.0)
+	assert.Equal(t, len(recognized.Characters), 8)
+	assert.Equal(t, recognized.Box, models.Box{Width: 1280, Height: 720})
+
+	// The same text fits the Mercosul layout with the second 6 read as a G, less likely
+	assert.Equal(t, recognized.Alternatives, []models.Candidate{{
+		Plate:      "GTJ6G99",
+		Confidence: 78,
+		Valid:      true,
+		Box:        models.Box{Width: 1280, Height: 720},
+	}})
+
+	_, err = usecases.Recognize(caller, "../assets/download2.jpg", "")
+	assert.Equal(t, err, utils.ErrImageRecognition)
+}
+
+// sceneReader reads the sign text on the whole image and a plate on any crop of it
+type sceneReader struct {
+	whole string
+}
+
+func (s sceneReader) Recognize(image []byte) (models.Reading, error) {
+	if recognition.Hash(image) == s.whole {
+		return models.Reading{Text: "SP GUARULHOS GTJ 6699", Confidence: 95}, nil
+	}
+	return models.Reading{Text: "GTJ 66O9", Confidence: 70}, nil
+}
+
+func TestRecognizeLocalized(t *testing.T) {
+	image, _ := ioutil.ReadFile("../assets/download.jpg")
+	usecases.SetRecognizer(sceneReader{whole: recognition.Hash(image)})
+	defer usecases.SetRecognizer(nil)
+
+	recognized, err := usecases.Recognize(caller, "../assets/download.jpg", "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, recognized.Plate, "GTJ-6609")
+	assert.Equal(t, recognized.Read, "GTJ66O9")
+	assert.Equal(t, recognized.Corrected, true)
+	assert.Equal(t, recognized.Confidence, 65.0)
+
+	// The sign is more confident but is not a plate
+	last := recognized.Alternatives[len(recognized.Alternatives)-1]
+	assert.Equal(t, last.Plate, "SPGUARULHOSGTJ6699")
+	assert.Equal(t, last.Valid, false)
+
+	// The characters of the plate, not the whole frame
+	box := recognized.Box
+	assert.Equal(t, box.X > 80 && box.X < 160, true)
+	assert.Equal(t, box.Y > 260 && box.Y < 340, true)
+	assert.Equal(t, box.Width > 900 && box.Width < 1100, true)
+	assert.Equal(t, box.Height > 150 && box.Height < 280, true)
+}
+
+// twoLanes puts the image twice apart, tinted red and blue, like a camera over two lanes
+func twoLanes(t *testing.T) []byte {
+	data, _ := ioutil.ReadFile("../assets/download.jpg")
+	img, err := imaging.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lane := imaging.Grayscale(img)
+	w, h := lane.Rect.Dx(), lane.Rect.Dy()
+	frame := image.NewRGBA(image.Rect(0, 0, 3*w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < 3*w; x++ {
+			frame.Set(x, y, color.Gray{Y: 128})
+		}
+		for x := 0; x < w; x++ {
+			p := lane.GrayAt(x, y).Y
+			frame.Set(x, y, color.RGBA{R: p, G: p / 2, B: p / 2, A: 255})
+			frame.Set(2*w+x, y, color.RGBA{R: p / 2, G: p / 2, B: p, A: 255})
+		}
+	}
+
+	encoded, err := imaging.Encode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+// laneReader reads one plate on red crops and another on blue ones, the whole frame is not a plate
+type laneReader struct {
+	whole string
+}
+
+func (l laneReader) Recognize(data []byte) (models.Reading, error) {
+	if recognition.Hash(data) == l.whole {
+		return models.Reading{Text: "GTJ 6699 BRA 3R52", Confidence: 95}, nil
+	}
 
-	request, _ := usecases.Recognize("../assets/download.jpg")
-	assert.Equal(t, request, models.ParkingRequest{
-		Plate: "GTJ-6699",
-	})
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return models.Reading{}, utils.ErrImageRecognition
+	}
 
-	request, _ = usecases.Recognize("../assets/download2.jpg")
-	assert.Equal(t, request, models.ParkingRequest{
-		Plate: "BRA3R52",
-	})
+	var red, blue int
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, _, bl, _ := img.At(x, y).RGBA()
+			red += int(r >> 8)
+			blue += int(bl >> 8)
+		}
+	}
+	if blue > red {
+		return recognition.Certain("BRA3R52"), nil
+	}
+	return models.Reading{Text: "GTJ-6699", Confidence: 90}, nil
 }
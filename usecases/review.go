@@ -0,0 +1,158 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// GetReviews lists the reviews with a status, pending by default
+func GetReviews(caller models.Caller, status string) ([]models.ReviewEntry, error) {
+	if status == "" {
+		status = models.ReviewPending
+	}
+
+	reviews, err := storage.Reviews(caller.Lot.ID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []models.ReviewEntry{}
+	for _, review := range reviews {
+		entries = append(entries, reviewEntry(review))
+	}
+
+	return entries, nil
+}
+
+// GetReview gets a review with its candidates
+func GetReview(caller models.Caller, token string) (models.ReviewEntry, error) {
+	review, err := storage.GetReview(caller.Lot.ID, token)
+	if err != nil {
+		return models.ReviewEntry{}, err
+	}
+
+	return reviewEntry(review), nil
+}
+
+// ReviewImage is the image a review was made from, gone once purged
+func ReviewImage(caller models.Caller, token string) ([]byte, error) {
+	review, err := storage.GetReview(caller.Lot.ID, token)
+	if err != nil {
+		return nil, err
+	}
+	if review.Image == "" {
+		return nil, utils.ErrNotFound
+	}
+
+	image, err := imageStore.Get(review.Image)
+	if err != nil && err != utils.ErrNotFound {
+		logrus.Warn(err.Error())
+		return nil, utils.ErrInternalServer
+	}
+
+	return image, err
+}
+
+// AcceptReview checks in the best reading of a review
+func AcceptReview(caller models.Caller, token string) (uint, error) {
+	review, err := storage.GetReview(caller.Lot.ID, token)
+	if err != nil {
+		return 0, err
+	}
+
+	return resolveReview(caller, token, review.Plate, models.ReviewAccepted)
+}
+
+// EditReview checks in the plate typed by the operator
+func EditReview(caller models.Caller, token string, request models.ReviewRequest) (uint, error) {
+	return resolveReview(caller, token, request.Plate, models.ReviewEdited)
+}
+
+// RejectReview closes a review without a ticket
+func RejectReview(caller models.Caller, token string) error {
+	return storage.RejectReview(caller.Lot.ID, token)
+}
+
+// resolveReview creates the ticket at the time the image was captured
+func resolveReview(caller models.Caller, token string, plate string, status string) (uint, error) {
+	plate = caller.Lot.NormalizePlate(plate)
+	if !caller.Lot.Validate(models.ParkingRequest{Plate: plate}) {
+		return 0, utils.ErrPlateNotValid
+	}
+
+	return storage.AcceptReview(caller.Lot.ID, token, plate, status, config.LotCapacity(caller.Lot), config.NoShowGrace())
+}
+
+// reviewEntry decodes the candidates of a review and works out how long it waited
+func reviewEntry(review models.Review) models.ReviewEntry {
+	entry := models.ReviewEntry{
+		Token:      review.Token,
+		Status:     review.Status,
+		Plate:      review.Plate,
+		Confidence: review.Confidence,
+		Candidates: []models.Candidate{},
+		CapturedAt: review.CapturedAt,
+		ResolvedAt: review.ResolvedAt,
+		ParkingID:  review.ParkingID,
+	}
+
+	if err := json.Unmarshal([]byte(review.Candidates), &entry.Candidates); err != nil {
+		logrus.Warn(err.Error())
+	}
+	if review.ResolvedAt != nil {
+		entry.ResolutionSeconds = int64(review.ResolvedAt.Sub(review.CapturedAt).Seconds())
+	}
+
+	return entry
+}
+
+// createReview keeps the image key and the readings, best first, under a random token in the caller's lot
+func createReview(caller models.Caller, recognized models.Recognition, image string, capturedAt time.Time) (string, error) {
+	candidates := recognized.Alternatives
+	if recognized.Plate != "" {
+		candidates = append([]models.Candidate{{
+			Plate:      recognized.Plate,
+			Confidence: recognized.Confidence,
+			Valid:      caller.Lot.Validate(models.ParkingRequest{Plate: recognized.Plate}),
+			Box:        recognized.Box,
+		}}, candidates...)
+	}
+	if candidates == nil {
+		candidates = []models.Candidate{}
+	}
+
+	encoded, err := json.Marshal(candidates)
+	if err != nil {
+		logrus.Warn(err.Error())
+		return "", utils.ErrInternalServer
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		logrus.Warn(err.Error())
+		return "", utils.ErrInternalServer
+	}
+
+	review := models.Review{
+		LotID:      caller.Lot.ID,
+		Token:      hex.EncodeToString(token),
+		Image:      image,
+		Plate:      recognized.Plate,
+		Confidence: recognized.Confidence,
+		Candidates: string(encoded),
+		CapturedAt: capturedAt,
+	}
+	if err := storage.CreateReview(review); err != nil {
+		return "", err
+	}
+
+	return review.Token, nil
+}
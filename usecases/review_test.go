@@ -0,0 +1,90 @@
+package usecases_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/recognition"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckinImageReview(t *testing.T) {
+	confident, _ := ioutil.ReadFile("../assets/download2.jpg")
+	blurry, _ := ioutil.ReadFile("../assets/download.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(confident): recognition.Certain("BRA3R52"),
+		recognition.Hash(blurry):    {Text: "GTJ-6699", Confidence: 40},
+	}))
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	result, err := usecases.CheckinImage(caller, confident, "")
+	assert.Equal(t, err, nil)
+	assert.Greater(t, result.ID, uint(0))
+	assert.Equal(t, result.Review, "")
+
+	result, err = usecases.CheckinImage(caller, blurry, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.ID, uint(0))
+	assert.Equal(t, len(result.Review), 32)
+	assert.Equal(t, result.Plate, "GTJ-6699")
+
+	// Nothing was checked in until the operator resolves it
+	_, err = usecases.GetReservations(caller, models.ParkingRequest{Plate: "GTJ-6699"}, false)
+	assert.Equal(t, err, utils.ErrNotFound)
+
+	pending, err := usecases.GetReviews(caller, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, pending[len(pending)-1].Token, result.Review)
+	assert.Equal(t, pending[len(pending)-1].Candidates[0].Plate, "GTJ-6699")
+
+	image, err := usecases.ReviewImage(caller, result.Review)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, image, blurry)
+
+	_, err = usecases.EditReview(caller, result.Review, models.ReviewRequest{Plate: "GTJ6699"})
+	assert.Equal(t, err, utils.ErrPlateNotValid)
+
+	id, err := usecases.AcceptReview(caller, result.Review)
+	assert.Equal(t, err, nil)
+	assert.Greater(t, id, uint(0))
+
+	history, err := usecases.GetReservations(caller, models.ParkingRequest{Plate: "GTJ-6699"}, false)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, history[0].ID, id)
+
+	_, err = usecases.AcceptReview(caller, result.Review)
+	assert.Equal(t, err, utils.ErrReviewClosed)
+	assert.Equal(t, usecases.RejectReview(caller, result.Review), utils.ErrReviewClosed)
+
+	_, err = usecases.AcceptReview(caller, "unknown")
+	assert.Equal(t, err, utils.ErrNotFound)
+}
+
+func TestCheckinImageUnreadable(t *testing.T) {
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{}))
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	image, _ := ioutil.ReadFile("../assets/download.jpg")
+	result, err := usecases.CheckinImage(caller, image, "")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.ID, uint(0))
+	assert.NotEqual(t, result.Review, "")
+
+	review, err := usecases.GetReview(caller, result.Review)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, review.Plate, "")
+	assert.Equal(t, review.Candidates, []models.Candidate{})
+
+	_, err = usecases.AcceptReview(caller, result.Review)
+	assert.Equal(t, err, utils.ErrPlateNotValid)
+
+	assert.Equal(t, usecases.RejectReview(caller, result.Review), nil)
+
+	review, _ = usecases.GetReview(caller, result.Review)
+	assert.Equal(t, review.Status, models.ReviewRejected)
+}
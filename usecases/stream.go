@@ -0,0 +1,144 @@
+package usecases
+
+import (
+	"net/http"
+	"time"
+
+	"br.com.mlabs/config"
+	"br.com.mlabs/images"
+	"br.com.mlabs/models"
+	"br.com.mlabs/storage"
+	"br.com.mlabs/stream"
+	"github.com/sirupsen/logrus"
+)
+
+// streamRetry is how long a dropped stream waits before reconnecting
+const streamRetry = 5 * time.Second
+
+// StartStreams watches every camera in STREAMS, each in its own goroutine
+func StartStreams() {
+	for name, camera := range config.Streams() {
+		go WatchStream(name, camera, nil)
+	}
+}
+
+// WatchStream reads a camera's frames and checks stable plates in or out, depending on its direction.
+// Dropped streams are reconnected until stop is closed, a nil stop watches forever
+func WatchStream(name string, camera stream.Camera, stop <-chan struct{}) {
+	sampler := stream.NewSampler(camera.Interval())
+	debouncer := stream.NewDebouncer(camera.DebounceWindow(), camera.Confirmations())
+
+	for {
+		err := watch(name, camera, sampler, debouncer, stop)
+		if err != nil {
+			logrus.Warnf("Stream %s dropped: %s", name, err.Error())
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(streamRetry):
+		}
+	}
+}
+
+// watch reads one connection to the stream until it ends or stop is closed
+func watch(name string, camera stream.Camera, sampler *stream.Sampler, debouncer *stream.Debouncer, stop <-chan struct{}) error {
+	caller, err := cameraCaller(camera)
+	if err != nil {
+		return err
+	}
+
+	reader, err := stream.Connect(http.DefaultClient, camera.URL)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+		case <-done:
+		}
+		reader.Close()
+	}()
+
+	for {
+		frame, err := reader.Next()
+		if err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		now := time.Now()
+		if !sampler.Take(now) {
+			continue
+		}
+
+		recognized, err := RecognizeImage(caller, frame, debugBase(images.Key(frame)), name)
+		if err != nil || recognized.Confidence < config.OCRMinConfidence() {
+			continue
+		}
+		if !caller.Lot.Validate(models.ParkingRequest{Plate: recognized.Plate}) {
+			continue
+		}
+
+		if debouncer.Seen(recognized.Plate, now) && sighted(caller, name, camera.Direction, recognized.Plate, frame) {
+			debouncer.Fired(recognized.Plate)
+		}
+	}
+}
+
+// cameraCaller is the operator of the camera's lot, the default lot when it names none
+func cameraCaller(camera stream.Camera) (models.Caller, error) {
+	if camera.Lot == "" {
+		return DefaultCaller()
+	}
+
+	lot, err := storage.LotByName(camera.Lot)
+	if err != nil {
+		return models.Caller{}, err
+	}
+
+	return models.Caller{Lot: lot, Role: models.RoleOperator}, nil
+}
+
+// sighted checks a stable plate in at entry cameras and out at exit cameras of the caller's lot.
+// It tells if the car went through, otherwise the plate is tried again on its next sighting
+func sighted(caller models.Caller, name string, direction string, plate string, frame []byte) bool {
+	switch direction {
+	case stream.Entry:
+		key, _, err := storeImage(frame)
+		if err != nil {
+			return false
+		}
+
+		id, err := MakeReservation(caller, models.ParkingRequest{Plate: plate, Image: key})
+		if err != nil {
+			discardImage(key)
+			logrus.Warnf("Camera %s could not check %s in: %s", name, plate, err.Error())
+			return false
+		}
+		logrus.Infof("Camera %s checked %s in, ticket %d", name, plate, id)
+		return true
+	case stream.Exit:
+		result, err := Exit(caller, plate)
+		switch {
+		case err != nil:
+			logrus.Warnf("Camera %s could not check %s out: %s", name, plate, err.Error())
+		case result.Open:
+			logrus.Infof("Camera %s checked %s out", name, plate)
+		default:
+			logrus.Infof("Camera %s saw %s leaving without paying %d", name, plate, result.AmountDue)
+		}
+		return err == nil && result.Open
+	default:
+		logrus.Warnf("Camera %s has no direction, %s was ignored", name, plate)
+		return true
+	}
+}
@@ -0,0 +1,166 @@
+package usecases_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"br.com.mlabs/models"
+	"br.com.mlabs/recognition"
+	"br.com.mlabs/stream"
+	"br.com.mlabs/usecases"
+	"github.com/stretchr/testify/assert"
+)
+
+// watchUntil watches a fake camera until done is true or the frames ran out
+func watchUntil(t *testing.T, camera stream.Camera, frames [][]byte, done func() bool) {
+	server := httptest.NewServer(stream.Fake{Frames: frames, Interval: 10 * time.Millisecond})
+	defer server.Close()
+
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	camera.URL = server.URL
+	go func() {
+		usecases.WatchStream("gate-1", camera, stop)
+		close(finished)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Let the remaining frames through, they must not trigger again
+	time.Sleep(time.Duration(len(frames)) * 10 * time.Millisecond)
+
+	close(stop)
+	<-finished
+}
+
+func TestWatchStreamEntry(t *testing.T) {
+	car, _ := ioutil.ReadFile("../assets/download2.jpg")
+	empty, _ := ioutil.ReadFile("../assets/download.png")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(car): recognition.Certain("STR3R52"),
+	}))
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	count := func() int64 {
+		var parked int64
+		tx.Model(&models.Parking{}).Where("plate = ?", "STR3R52").Count(&parked)
+		return parked
+	}
+
+	// The car stands in front of the camera for a while
+	frames := [][]byte{empty, car, car, car, car, empty, car, car}
+	watchUntil(t, stream.Camera{Direction: stream.Entry}, frames, func() bool {
+		return count() > 0
+	})
+
+	assert.Equal(t, count(), int64(1))
+}
+
+func TestWatchStreamEntryFull(t *testing.T) {
+	// Trailing bytes make a content no ticket points at yet
+	car, _ := ioutil.ReadFile("../assets/download2.jpg")
+	car = append(car, []byte("lot full")...)
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(car): recognition.Certain("STR-1111"),
+	}))
+	defer usecases.SetRecognizer(nil)
+	defer useTempImageStore(t)()
+
+	var parked, held int64
+	tx.Model(&models.Parking{}).Where("checkout IS NULL").Count(&parked)
+	tx.Model(&models.Booking{}).Where("status = ? AND starts_at <= now() AND ends_at > now()", models.BookingBooked).Count(&held)
+	os.Setenv("PARKING_CAPACITY", strconv.Itoa(int(parked+held)))
+	defer os.Unsetenv("PARKING_CAPACITY")
+
+	// Every sighting is turned away and none of the frames is kept
+	watchUntil(t, stream.Camera{Direction: stream.Entry}, [][]byte{car, car, car, car, car, car}, func() bool {
+		return false
+	})
+
+	stored, _ := ioutil.ReadDir(imageStore.Dir)
+	assert.Equal(t, len(stored), 0)
+}
+
+func TestWatchStreamExit(t *testing.T) {
+	car, _ := ioutil.ReadFile("../assets/download2.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(car): recognition.Certain("STR-4321"),
+	}))
+	defer usecases.SetRecognizer(nil)
+
+	id, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "STR-4321"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, usecases.Pay(caller, fmt.Sprint(id), models.PaymentRequest{Method: "cash"}), nil)
+
+	left := func() bool {
+		var parking models.Parking
+		tx.First(&parking, id)
+		return parking.Checkout != nil
+	}
+
+	watchUntil(t, stream.Camera{Direction: stream.Exit, Confirm: 3}, [][]byte{car, car, car, car}, left)
+
+	assert.Equal(t, left(), true)
+}
+
+func TestWatchStreamUnpaid(t *testing.T) {
+	car, _ := ioutil.ReadFile("../assets/download2.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(car): recognition.Certain("STR-9876"),
+	}))
+	defer usecases.SetRecognizer(nil)
+
+	id, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "STR-9876"})
+	assert.Equal(t, err, nil)
+
+	// The gate stays closed
+	watchUntil(t, stream.Camera{Direction: stream.Exit}, [][]byte{car, car, car}, func() bool {
+		return false
+	})
+
+	var parking models.Parking
+	tx.First(&parking, id)
+	assert.Equal(t, parking.Checkout, (*time.Time)(nil))
+}
+
+func TestWatchStreamPaidAtGate(t *testing.T) {
+	car, _ := ioutil.ReadFile("../assets/download2.jpg")
+	usecases.SetRecognizer(recognition.NewFake(map[string]models.Reading{
+		recognition.Hash(car): recognition.Certain("STR-5555"),
+	}))
+	defer usecases.SetRecognizer(nil)
+
+	id, err := usecases.MakeReservation(caller, models.ParkingRequest{Plate: "STR-5555"})
+	assert.Equal(t, err, nil)
+
+	left := func() bool {
+		var parking models.Parking
+		tx.First(&parking, id)
+		return parking.Checkout != nil
+	}
+
+	// Turned away at first, the driver pays while standing at the gate and it opens
+	frames := make([][]byte, 30)
+	for i := range frames {
+		frames[i] = car
+	}
+	start := time.Now()
+	paid := false
+	watchUntil(t, stream.Camera{Direction: stream.Exit}, frames, func() bool {
+		if !paid && time.Since(start) > 100*time.Millisecond {
+			paid = usecases.Pay(caller, fmt.Sprint(id), models.PaymentRequest{Method: "cash"}) == nil
+		}
+		return left()
+	})
+
+	assert.Equal(t, paid, true)
+	assert.Equal(t, left(), true)
+}
@@ -0,0 +1,28 @@
+//go:build tesseract
+// +build tesseract
+
+package usecases_test
+
+import (
+	"testing"
+
+	"br.com.mlabs/recognition/tesseract"
+	"br.com.mlabs/usecases"
+	"br.com.mlabs/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecognize(t *testing.T) {
+	usecases.SetRecognizer(tesseract.Recognizer{})
+	defer usecases.SetRecognizer(nil)
+
+	_, err := usecases.Recognize(caller, "notfound", "")
+
+	assert.Equal(t, err, utils.ErrImageRecognition)
+
+	recognized, _ := usecases.Recognize(caller, "../assets/download.jpg", "")
+	assert.Equal(t, recognized.Plate, "GTJ-6699")
+
+	recognized, _ = usecases.Recognize(caller, "../assets/download2.jpg", "")
+	assert.Equal(t, recognized.Plate, "BRA3R52")
+}
@@ -12,7 +12,7 @@ var (
 	// ErrAlreadyCheckedOut is an already checked out error
 	ErrAlreadyCheckedOut = errors.New("You have already checked out")
 	// ErrPlateNotValid is a validation error
-	ErrPlateNotValid = errors.New("Plate must be valid, format: AAA-1234")
+	ErrPlateNotValid = errors.New("Plate must be valid")
 	// ErrBadRequest is a request-reading error
 	ErrBadRequest = errors.New("Bad request")
 	// ErrInternalServer is an internal problem
@@ -21,6 +21,54 @@ var (
 	ErrImageRecognition = errors.New("Image recognition failed")
 	// ErrNotFound is used when we can't find
 	ErrNotFound = errors.New("Not found")
+	// ErrLotFull is used when there is no space left in the lot
+	ErrLotFull = errors.New("There are no spaces left")
+	// ErrCategoryNotValid is a vehicle category validation error
+	ErrCategoryNotValid = errors.New("Category must be one of: motorcycle, car, van, truck")
+	// ErrBookingNotValid is a booking validation error
+	ErrBookingNotValid = errors.New("Booking must have a valid plate and a future time window")
+	// ErrBookingClosed is used when a booking is no longer held
+	ErrBookingClosed = errors.New("Booking is no longer active")
+	// ErrTicketNotValid is used when a ticket token is not genuine
+	ErrTicketNotValid = errors.New("Ticket must be valid")
+	// ErrPaymentNotValid is a payment validation error
+	ErrPaymentNotValid = errors.New("Payment method must be one of: cash, credit, debit, pix")
+	// ErrNotPaid is used when a receipt is asked for an unpaid parking space
+	ErrNotPaid = errors.New("There is no payment for this parking space")
+	// ErrDateRangeNotValid is a report range validation error
+	ErrDateRangeNotValid = errors.New("Date range must be valid, format: YYYY-MM-DD, up to 366 days")
+	// ErrExportNotValid is used when the export format or columns are unknown
+	ErrExportNotValid = errors.New("Export must be csv or ndjson, columns: id, plate, checkin, checkout, prepaid, paid, amount, method, paid_at")
+	// ErrImportNotValid is an import batch validation error
+	ErrImportNotValid = errors.New("Import must have a device id and from 1 to 1000 events")
+	// ErrEventNotValid is an import event validation error
+	ErrEventNotValid = errors.New("Event must have an id, a type, a past time and its plate or ticket")
+	// ErrIdempotencyKeyReused is used when an idempotency key comes with a different request
+	ErrIdempotencyKeyReused = errors.New("Idempotency key was used with a different request")
+	// ErrIdempotencyInProgress is used when the first request with an idempotency key has not finished
+	ErrIdempotencyInProgress = errors.New("A request with this idempotency key is in progress")
+	// ErrReviewClosed is used when a review was already resolved
+	ErrReviewClosed = errors.New("Review was already resolved")
+	// ErrImageTooLarge is used when an upload is over the byte or pixel limit
+	ErrImageTooLarge = errors.New("Image is too large")
+	// ErrBodyTooLarge is used when a JSON request body is over the byte limit
+	ErrBodyTooLarge = errors.New("Request body is too large")
+	// ErrImageNotSupported is used when an upload is not an image that can be read
+	ErrImageNotSupported = errors.New("Image must be JPEG, PNG or WebP")
+	// ErrStreamNotValid is used when a camera stream is not MJPEG
+	ErrStreamNotValid = errors.New("Stream must be multipart/x-mixed-replace")
+	// ErrSeveralOpen is used when a plate has more than one open ticket, it cannot tell which one is leaving
+	ErrSeveralOpen = errors.New("There is more than one open ticket for this plate")
+	// ErrUnauthorized is used when a request has no API key, or one that is not known
+	ErrUnauthorized = errors.New("API key must be valid")
+	// ErrForbidden is used when an operator asks for what only admins may do
+	ErrForbidden = errors.New("Only admins can do this")
+	// ErrLotNotValid is a lot validation error
+	ErrLotNotValid = errors.New("Lot must have a name and categories from: motorcycle, car, van, truck")
+	// ErrLotExists is used when a lot is created with the name of another
+	ErrLotExists = errors.New("There is already a lot with this name")
+	// ErrUserNotValid is a user validation error
+	ErrUserNotValid = errors.New("User must have a name and a role: operator, admin")
 	// ErrMethodNotAllowed is used when a method is not allowed for an endpoint
 	ErrMethodNotAllowed = errors.New("Method not allowed")
 )